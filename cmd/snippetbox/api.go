@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/highlight"
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+	"github.com/huytran2000-hcmus/snippetbox/internal/validator"
+	"github.com/huytran2000-hcmus/snippetbox/ui"
+)
+
+// maxAPIBodySize is how many bytes an API request body can have.
+const maxAPIBodySize = 1 << 20
+
+type apiFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+type apiSnippet struct {
+	Slug   string `json:"slug"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Author string `json:"author,omitempty"`
+	UserID int    `json:"user_id"`
+	// Files are left out of listings.
+	Files            []apiFile  `json:"files,omitempty"`
+	Visibility       string     `json:"visibility"`
+	Tags             []string   `json:"tags"`
+	Created          time.Time  `json:"created"`
+	Updated          time.Time  `json:"updated"`
+	Expires          *time.Time `json:"expires"`
+	BurnAfterReading bool       `json:"burn_after_reading"`
+	Protected        bool       `json:"protected"`
+	// ForkedFrom is the slug of the snippet this one was forked from. It's
+	// left out of listings.
+	ForkedFrom string `json:"forked_from,omitempty"`
+	StarCount  int    `json:"star_count"`
+}
+
+type apiSnippetList struct {
+	Snippets []apiSnippet `json:"snippets"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int          `json:"total"`
+}
+
+// apiSnippetInput is the body of the requests creating and updating
+// snippets. Fields left out keep their current value on update, or get the
+// same default as the create form.
+type apiSnippetInput struct {
+	Title      *string   `json:"title"`
+	Files      []apiFile `json:"files"`
+	Expires    *string   `json:"expires"`
+	ExpiresAt  *string   `json:"expires_at"`
+	Visibility *string   `json:"visibility"`
+	Tags       []string  `json:"tags"`
+	Password   *string   `json:"password"`
+	// RemovePassword removes the password of the snippet on update.
+	RemovePassword bool `json:"remove_password"`
+}
+
+// apiError is the body of every API error response. Fields maps the JSON
+// path of invalid fields to what's wrong with them.
+type apiError struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Errors []string          `json:"errors,omitempty"`
+}
+
+// openAPI serves the OpenAPI document of the API, kept in sync with the
+// handlers by TestOpenAPI.
+func openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ui.OpenAPI)
+}
+
+func (app *Application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+	p := readPagination(r, &v)
+	if !v.IsValid() {
+		app.apiValidationError(w, http.StatusBadRequest, &v)
+		return
+	}
+
+	snippets, total, err := app.snippet.List(p.Page, p.PageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	p.Total = total
+
+	app.writeJSON(w, http.StatusOK, app.newAPISnippetList(snippets, p))
+}
+
+// apiSnippetView returns a snippet, burning burn-after-reading snippets of
+// other users like snippetView does. Password protected snippets can only be
+// read by their author through the API.
+func (app *Application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	if s.Protected && s.UserID != userID {
+		app.apiError(w, http.StatusForbidden, "This snippet is password protected")
+		return
+	}
+
+	if s.BurnAfterReading && s.UserID != userID {
+		var err error
+		s, err = app.snippet.Burn(s.ID)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			return
+		}
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+	}
+
+	app.writeJSON(w, http.StatusOK, app.newAPISnippet(s))
+}
+
+func (app *Application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetInput
+	if !app.readJSON(w, r, &input) {
+		return
+	}
+
+	form := input.form(nil)
+	s := form.validate(nil)
+	if !form.IsValid() {
+		app.apiValidationError(w, http.StatusUnprocessableEntity, &form.Validator)
+		return
+	}
+
+	s.UserID = app.authenticatedUserID(r)
+	err := app.snippet.Insert(s)
+	if errors.Is(err, models.ErrPasswordTooLong) {
+		form.AddFieldError("password", "The password is too long")
+		app.apiValidationError(w, http.StatusUnprocessableEntity, &form.Validator)
+		return
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	s.Updated = s.Created
+
+	w.Header().Set("Location", "/api/v1/snippets/"+s.Slug)
+	app.writeJSON(w, http.StatusCreated, app.newAPISnippet(s))
+}
+
+func (app *Application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var input apiSnippetInput
+	if !app.readJSON(w, r, &input) {
+		return
+	}
+
+	form := input.form(s)
+	updated := form.validate(s)
+	if !form.IsValid() {
+		app.apiValidationError(w, http.StatusUnprocessableEntity, &form.Validator)
+		return
+	}
+
+	updated.ID = s.ID
+	updated.Slug = s.Slug
+	updated.UserID = s.UserID
+	updated.UserName = s.UserName
+	updated.Created = s.Created
+	updated.ForkedFrom = s.ForkedFrom
+	updated.ForkedFromSlug = s.ForkedFromSlug
+	updated.StarCount = s.StarCount
+	err := app.snippet.Update(updated, app.authenticatedUserID(r))
+	if errors.Is(err, models.ErrPasswordTooLong) {
+		form.AddFieldError("password", "The password is too long")
+		app.apiValidationError(w, http.StatusUnprocessableEntity, &form.Validator)
+		return
+	}
+	if errors.Is(err, models.ErrNoRecord) {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	updated.Updated = time.Now()
+
+	app.writeJSON(w, http.StatusOK, app.newAPISnippet(updated))
+}
+
+func (app *Application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippet.Delete(s.ID)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiFindSnippet is the API counterpart of findSnippet.
+func (app *Application) apiFindSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, err := app.getSnippet(r)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return nil, false
+	}
+	if err != nil {
+		app.apiServerError(w, err)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// apiOwnedSnippet is the API counterpart of ownedSnippet.
+func (app *Application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.apiFindSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	userID := app.authenticatedUserID(r)
+	if s.BurnAfterReading && s.UserID != userID {
+		app.apiError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return nil, false
+	}
+
+	if s.UserID != userID {
+		app.apiError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		return nil, false
+	}
+
+	return s, true
+}
+
+// form turns the input into the form the web pages submit, so both are
+// checked by the same rules. current is the snippet being updated, nil when
+// creating one.
+func (input *apiSnippetInput) form(current *models.Snippet) *snippetCreateForm {
+	form := &snippetCreateForm{
+		Files:      []snippetFileForm{{Language: highlight.PlainText}},
+		Expires:    defaultExpiry,
+		Visibility: models.VisibilityPublic,
+	}
+	if current != nil {
+		form.Title = current.Title
+		form.Files = newSnippetFileForms(current.Files)
+		form.Expires = expiryKeep
+		form.Visibility = current.Visibility
+		form.Tags = strings.Join(current.Tags, ",")
+	}
+
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	if input.Files != nil {
+		form.Files = make([]snippetFileForm, len(input.Files))
+		for i, f := range input.Files {
+			form.Files[i] = snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content}
+			if f.Language == "" {
+				form.Files[i].Language = highlight.PlainText
+			}
+		}
+	}
+	if input.Expires != nil {
+		form.Expires = *input.Expires
+	}
+	if input.ExpiresAt != nil {
+		form.ExpiresAt = *input.ExpiresAt
+		// Clients send RFC 3339 dates, which the form field doesn't take.
+		if t, err := time.Parse(time.RFC3339, form.ExpiresAt); err == nil {
+			form.ExpiresAt = t.UTC().Format(expiresAtLayout)
+		}
+	}
+	if input.Visibility != nil {
+		form.Visibility = *input.Visibility
+	}
+	if input.Tags != nil {
+		form.Tags = strings.Join(input.Tags, ",")
+	}
+	if input.Password != nil {
+		form.Password = *input.Password
+	}
+	form.RemovePassword = input.RemovePassword
+
+	return form
+}
+
+func (app *Application) newAPISnippet(s *models.Snippet) apiSnippet {
+	out := apiSnippet{
+		Slug:             s.Slug,
+		URL:              app.publicURL + "/s/" + s.Slug,
+		Title:            s.Title,
+		Author:           s.UserName,
+		UserID:           s.UserID,
+		Visibility:       s.Visibility,
+		Tags:             s.Tags,
+		Created:          s.Created,
+		Updated:          s.Updated,
+		BurnAfterReading: s.BurnAfterReading,
+		Protected:        s.Protected,
+		ForkedFrom:       s.ForkedFromSlug,
+		StarCount:        s.StarCount,
+	}
+	if out.Tags == nil {
+		out.Tags = []string{}
+	}
+	if !s.Expires.IsZero() {
+		expires := s.Expires
+		out.Expires = &expires
+	}
+	for _, f := range s.Files {
+		out.Files = append(out.Files, apiFile{Name: f.Name, Language: f.Language, Content: f.Content})
+	}
+
+	return out
+}
+
+// newAPISnippetList returns the page p of a listing of snippets.
+func (app *Application) newAPISnippetList(snippets []models.Snippet, p *pagination) apiSnippetList {
+	list := apiSnippetList{Snippets: []apiSnippet{}, Page: p.Page, PageSize: p.PageSize, Total: p.Total}
+	for i := range snippets {
+		list.Snippets = append(list.Snippets, app.newAPISnippet(&snippets[i]))
+	}
+
+	return list
+}
+
+// readJSON decodes the JSON object in the body of r into dst. It writes the
+// error response itself and reports false when the handler should stop.
+func (app *Application) readJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodySize)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must only hold a single JSON object")
+	}
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, fmt.Sprintf("The request body is invalid: %s", err))
+		return false
+	}
+
+	return true
+}
+
+func (app *Application) writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+func (app *Application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, apiError{Error: message})
+}
+
+// apiValidationError reports the errors of v, keyed by field name for the
+// field errors.
+func (app *Application) apiValidationError(w http.ResponseWriter, status int, v *validator.Validator) {
+	app.writeJSON(w, status, apiError{
+		Error:  "The request has invalid fields",
+		Fields: v.FieldErrs,
+		Errors: v.NonFieldErrs,
+	})
+}
+
+func (app *Application) apiServerError(w http.ResponseWriter, err error) {
+	app.errLog.Output(2, fmt.Sprintf("%s\n%s", err.Error(), debug.Stack()))
+	app.apiError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
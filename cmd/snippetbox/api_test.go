@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+// newAPIRequest builds an API request sent with a mock token of user, one of
+// "alice" and "bob", or anonymously for an empty user. Alice's token has
+// every scope, while Bob reads and writes with separate tokens.
+func newAPIRequest(method string, path string, body string, user string) *http.Request {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.RequestURI = ""
+
+	switch {
+	case user == "alice":
+		req.Header.Set("Authorization", "Bearer sbp_alicereadwrite")
+	case user == "bob" && method == http.MethodGet:
+		req.Header.Set("Authorization", "Bearer sbp_bobreadonly")
+	case user == "bob":
+		req.Header.Set("Authorization", "Bearer sbp_bobwriteonly")
+	}
+	return req
+}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, header, body := ts.Do(t, newAPIRequest(http.MethodGet, "/api/v1/snippets", "", ""))
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+	assert.Equal(t, header.Get("Set-Cookie"), "")
+
+	var list apiSnippetList
+	err := json.Unmarshal([]byte(body), &list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, list.Total, 1)
+	assert.Equal(t, list.Page, 1)
+	assert.Equal(t, len(list.Snippets), 1)
+	assert.Equal(t, list.Snippets[0].Slug, "pondXy12Ab")
+	assert.Equal(t, list.Snippets[0].URL, app.publicURL+"/s/pondXy12Ab")
+
+	status, _, body = ts.Do(t, newAPIRequest(http.MethodGet, "/api/v1/snippets?page=0", "", ""))
+	assert.Equal(t, status, http.StatusBadRequest)
+	assert.StringContains(t, body, `"fields":{"page":`)
+}
+
+func TestAPISnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		user       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Public snippet",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			wantStatus: http.StatusOK,
+			wantBody:   `"files":[{"name":"pond.txt","language":"plaintext","content":"An old silent pond..."}]`,
+		},
+		{
+			name:       "By ID",
+			urlPath:    "/api/v1/snippets/1",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"Not Found"}`,
+		},
+		{
+			name:       "Private snippet anonymously",
+			urlPath:    "/api/v1/snippets/forest34Cd",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"Not Found"}`,
+		},
+		{
+			name:       "Private snippet of the author",
+			urlPath:    "/api/v1/snippets/forest34Cd",
+			user:       "alice",
+			wantStatus: http.StatusOK,
+			wantBody:   `"visibility":"private"`,
+		},
+		{
+			name:       "Password protected snippet",
+			urlPath:    "/api/v1/snippets/lockedZ9Kq",
+			user:       "bob",
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"error":"This snippet is password protected"}`,
+		},
+		{
+			name:       "Non-existent snippet",
+			urlPath:    "/api/v1/snippets/missing999",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, body := ts.Do(t, newAPIRequest(http.MethodGet, tt.urlPath, "", tt.user))
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const valid = `{"title": "Hello", "files": [{"name": "main.go", "language": "go", "content": "package main"}], "tags": ["go"]}`
+
+	tests := []struct {
+		name         string
+		body         string
+		user         string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid",
+			body:         valid,
+			user:         "alice",
+			wantStatus:   http.StatusCreated,
+			wantLocation: "/api/v1/snippets/newSnip56E",
+			wantBody:     `"slug":"newSnip56E"`,
+		},
+		{
+			name:       "Anonymous",
+			body:       valid,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":"Valid credentials are required"}`,
+		},
+		{
+			name:       "Invalid fields",
+			body:       `{"title": "", "files": [{"content": " "}], "visibility": "secret"}`,
+			user:       "alice",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"fields":{"files[0].content":"This field can't be blank","title":"This field can't be blank","visibility":"This field must be public, unlisted or private"}`,
+		},
+		{
+			name:       "Custom expiry in the past",
+			body:       `{"title": "Hello", "files": [{"content": "Hi"}], "expires": "custom", "expires_at": "2000-01-02T15:04:05Z"}`,
+			user:       "alice",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `"expires_at":"This field must be in the future"`,
+		},
+		{
+			name:       "Unknown field",
+			body:       `{"title": "Hello", "content": "Hi"}`,
+			user:       "alice",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `unknown field \"content\"`,
+		},
+		{
+			name:       "Not JSON",
+			body:       `title=Hello`,
+			user:       "alice",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Several objects",
+			body:       valid + valid,
+			user:       "alice",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header, body := ts.Do(t, newAPIRequest(http.MethodPost, "/api/v1/snippets", tt.body, tt.user))
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+
+	t.Run("Email and password", func(t *testing.T) {
+		req := newAPIRequest(http.MethodPost, "/api/v1/snippets", valid, "")
+		req.SetBasicAuth("alice@example.com", "pa$$word")
+		status, header, _ := ts.Do(t, req)
+
+		assert.Equal(t, status, http.StatusUnauthorized)
+		assert.Equal(t, strings.Join(header.Values("WWW-Authenticate"), ", "), `Bearer realm="snippetbox"`)
+	})
+}
+
+func TestAPISnippetUpdate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		body       string
+		user       string
+		wantStatus int
+		wantBody   []string
+	}{
+		{
+			name:       "Partial update",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			body:       `{"title": "A new pond"}`,
+			user:       "alice",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"title":"A new pond"`, `"name":"pond.txt"`, `"tags":["haiku","nature"]`},
+		},
+		{
+			name:       "Clear tags",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			body:       `{"tags": []}`,
+			user:       "alice",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`"tags":[]`},
+		},
+		{
+			name:       "Invalid field",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			body:       `{"tags": ["Not a tag!"]}`,
+			user:       "alice",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{`"fields":{"tags":`},
+		},
+		{
+			name:       "Someone else",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			body:       `{"title": "Mine now"}`,
+			user:       "bob",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Anonymous",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			body:       `{"title": "Mine now"}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Non-existent snippet",
+			urlPath:    "/api/v1/snippets/missing999",
+			body:       `{"title": "Hello"}`,
+			user:       "alice",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, body := ts.Do(t, newAPIRequest(http.MethodPatch, tt.urlPath, tt.body, tt.user))
+
+			assert.Equal(t, status, tt.wantStatus)
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		user       string
+		wantStatus int
+	}{
+		{
+			name:       "Author",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			user:       "alice",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Someone else",
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			user:       "bob",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Someone else's private snippet",
+			urlPath:    "/api/v1/snippets/forest34Cd",
+			user:       "bob",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, _ := ts.Do(t, newAPIRequest(http.MethodDelete, tt.urlPath, "", tt.user))
+
+			assert.Equal(t, status, tt.wantStatus)
+		})
+	}
+}
+
+func TestAPIBearerToken(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const valid = `{"title": "Hello", "files": [{"content": "Hi"}]}`
+
+	tests := []struct {
+		name       string
+		method     string
+		urlPath    string
+		body       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Read with a read token",
+			method:     http.MethodGet,
+			urlPath:    "/api/v1/snippets/pondXy12Ab",
+			token:      "sbp_bobreadonly",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Read a private snippet as its author",
+			method:     http.MethodGet,
+			urlPath:    "/api/v1/snippets/forest34Cd",
+			token:      "sbp_alicereadwrite",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Read with a write token",
+			method:     http.MethodGet,
+			urlPath:    "/api/v1/snippets",
+			token:      "sbp_bobwriteonly",
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"error":"The token needs the snippets:read scope"}`,
+		},
+		{
+			name:       "Write with a write token",
+			method:     http.MethodPost,
+			urlPath:    "/api/v1/snippets",
+			body:       valid,
+			token:      "sbp_bobwriteonly",
+			wantStatus: http.StatusCreated,
+			wantBody:   `"user_id":2`,
+		},
+		{
+			name:       "Write with a read token",
+			method:     http.MethodPost,
+			urlPath:    "/api/v1/snippets",
+			body:       valid,
+			token:      "sbp_bobreadonly",
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"error":"The token needs the snippets:write scope"}`,
+		},
+		{
+			name:       "Unknown token",
+			method:     http.MethodGet,
+			urlPath:    "/api/v1/snippets",
+			token:      "sbp_revoked",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAPIRequest(tt.method, tt.urlPath, tt.body, "")
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			status, _, body := ts.Do(t, req)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
@@ -2,4 +2,13 @@ package main
 
 type contextKey string
 
-const isAuthenticatedCtxKey = contextKey("isAuthenticated")
+const (
+	isAuthenticatedCtxKey = contextKey("isAuthenticated")
+	userIDCtxKey          = contextKey("userID")
+	// tokenCtxKey holds the personal access token an API request was
+	// authenticated with, if any.
+	tokenCtxKey = contextKey("token")
+	// plainErrorsCtxKey is set on routes serving raw content, whose errors
+	// are plain text unless another format is preferred.
+	plainErrorsCtxKey = contextKey("plainErrors")
+)
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+// feedSize is how many of the latest snippets a feed holds.
+const feedSize = 20
+
+// feed is what the Atom and RSS feeds are built from.
+type feed struct {
+	Title    string
+	Path     string
+	Query    string
+	Snippets []models.Snippet
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    *atomContent   `xml:"content,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description,omitempty"`
+}
+
+func (app *Application) feedAtom(w http.ResponseWriter, r *http.Request) {
+	f, ok := app.readFeed(w, r)
+	if !ok {
+		return
+	}
+
+	base := app.publicURL
+	self := base + "/feed.atom" + f.Query
+	atom := atomFeed{
+		ID:      self,
+		Title:   f.Title,
+		Updated: feedTimestamp(f.Updated()),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+			{Rel: "alternate", Type: "text/html", Href: base + f.Path},
+		},
+	}
+	for _, s := range f.Snippets {
+		link := base + "/s/" + s.Slug
+		entry := atomEntry{
+			ID:        link,
+			Title:     s.Title,
+			Published: feedTimestamp(s.Created),
+			Updated:   feedTimestamp(s.Updated),
+			Author:    atomPerson{Name: s.UserName},
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: link},
+		}
+		for _, tag := range s.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if !s.Protected {
+			entry.Content = &atomContent{Type: "text", Body: s.Content}
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	app.writeFeed(w, r, "application/atom+xml; charset=utf-8", atom)
+}
+
+func (app *Application) feedRSS(w http.ResponseWriter, r *http.Request) {
+	f, ok := app.readFeed(w, r)
+	if !ok {
+		return
+	}
+
+	base := app.publicURL
+	rss := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          base + f.Path,
+			Description:   f.Title + " on Snippetbox",
+			LastBuildDate: f.Updated().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, s := range f.Snippets {
+		link := base + "/s/" + s.Slug
+		item := rssItem{
+			Title:      s.Title,
+			Link:       link,
+			GUID:       link,
+			PubDate:    s.Created.UTC().Format(time.RFC1123Z),
+			Author:     s.UserName,
+			Categories: s.Tags,
+		}
+		if !s.Protected {
+			item.Description = s.Content
+		}
+		rss.Channel.Items = append(rss.Channel.Items, item)
+	}
+
+	app.writeFeed(w, r, "application/rss+xml; charset=utf-8", rss)
+}
+
+// readFeed loads the latest listed snippets, of the user given by the user
+// query parameter or carrying the tag given by the tag query parameter if
+// either is set. It writes the error response itself and reports false when
+// the handler should stop.
+func (app *Application) readFeed(w http.ResponseWriter, r *http.Request) (*feed, bool) {
+	query := r.URL.Query()
+	f := &feed{Title: "Latest Snippets", Path: "/"}
+
+	var err error
+	switch {
+	case query.Has("tag"):
+		tag := query.Get("tag")
+		if !tagRX.MatchString(tag) {
+			app.notFound(w, r)
+			return nil, false
+		}
+		f.Title = "Snippets tagged " + tag
+		f.Path = "/tag/" + tag
+		f.Query = "?tag=" + url.QueryEscape(tag)
+		f.Snippets, _, err = app.snippet.ByTag(tag, 1, feedSize)
+	case query.Has("user"):
+		userID, atoiErr := strconv.Atoi(query.Get("user"))
+		if atoiErr != nil || userID < 1 {
+			app.notFound(w, r)
+			return nil, false
+		}
+		user, getErr := app.users.Get(userID)
+		if errors.Is(getErr, models.ErrNoRecord) {
+			app.notFound(w, r)
+			return nil, false
+		}
+		if getErr != nil {
+			app.serverError(w, r, getErr)
+			return nil, false
+		}
+		f.Title = "Snippets by " + user.Name
+		f.Query = "?user=" + strconv.Itoa(userID)
+		f.Snippets, _, err = app.snippet.ByAuthor(userID, 1, feedSize)
+	default:
+		f.Snippets, _, err = app.snippet.List(1, feedSize)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	return f, true
+}
+
+// Updated returns when the latest of the feed snippets was updated, or the
+// Unix epoch for an empty feed, so it doesn't change between polls.
+func (f *feed) Updated() time.Time {
+	updated := time.Unix(0, 0)
+	for _, s := range f.Snippets {
+		if s.Updated.After(updated) {
+			updated = s.Updated
+		}
+	}
+
+	return updated
+}
+
+// writeFeed writes v as an XML document tagged with an ETag of its content,
+// or just a 304 when the client already has that version.
+func (app *Application) writeFeed(w http.ResponseWriter, r *http.Request, contentType string, v any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	err := xml.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	buf.WriteTo(w)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak and
+// strong validators compare equal, as RFC 9110 asks for this header.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// feedTimestamp formats tm as an Atom date, like timestamp but in RFC 3339.
+func feedTimestamp(tm time.Time) string {
+	return tm.UTC().Format(time.RFC3339)
+}
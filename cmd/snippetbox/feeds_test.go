@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestFeeds(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantStatus int
+		wantType   string
+		wantBody   []string
+	}{
+		{
+			name:       "Atom",
+			urlPath:    "/feed.atom",
+			wantStatus: http.StatusOK,
+			wantType:   "application/atom+xml; charset=utf-8",
+			wantBody: []string{
+				`<feed xmlns="http://www.w3.org/2005/Atom">`,
+				"<title>Latest Snippets</title>",
+				"<updated>2023-05-11T08:30:00Z</updated>",
+				"<id>" + app.publicURL + "/s/pondXy12Ab</id>",
+				"<published>2023-05-10T20:00:00Z</published>",
+				`<link rel="alternate" type="text/html" href="` + app.publicURL + `/s/pondXy12Ab"></link>`,
+				`<category term="haiku"></category>`,
+			},
+		},
+		{
+			name:       "RSS",
+			urlPath:    "/feed.rss",
+			wantStatus: http.StatusOK,
+			wantType:   "application/rss+xml; charset=utf-8",
+			wantBody: []string{
+				`<rss version="2.0"`,
+				"<link>" + app.publicURL + "/s/pondXy12Ab</link>",
+				"<pubDate>Wed, 10 May 2023 20:00:00 +0000</pubDate>",
+				"<lastBuildDate>Thu, 11 May 2023 08:30:00 +0000</lastBuildDate>",
+				"<dc:creator>alice</dc:creator>",
+			},
+		},
+		{
+			name:       "Tag",
+			urlPath:    "/feed.atom?tag=haiku",
+			wantStatus: http.StatusOK,
+			wantType:   "application/atom+xml; charset=utf-8",
+			wantBody:   []string{"<title>Snippets tagged haiku</title>", app.publicURL + "/feed.atom?tag=haiku"},
+		},
+		{
+			name:       "User",
+			urlPath:    "/feed.rss?user=1",
+			wantStatus: http.StatusOK,
+			wantType:   "application/rss+xml; charset=utf-8",
+			wantBody:   []string{"<title>Snippets by alice</title>"},
+		},
+		{
+			name:       "User without snippets",
+			urlPath:    "/feed.atom?user=2",
+			wantStatus: http.StatusOK,
+			wantType:   "application/atom+xml; charset=utf-8",
+			wantBody:   []string{"<title>Snippets by bob</title>"},
+		},
+		{
+			name:       "Unknown user",
+			urlPath:    "/feed.atom?user=99",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid tag",
+			urlPath:    "/feed.atom?tag=%3Cb%3E",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid user",
+			urlPath:    "/feed.atom?user=alice",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header, body := ts.Get(t, tt.urlPath)
+
+			assert.Equal(t, status, tt.wantStatus)
+			if tt.wantType != "" {
+				assert.Equal(t, header.Get("Content-Type"), tt.wantType)
+				assert.Equal(t, xml.Unmarshal([]byte(body), new(struct{})), nil)
+			}
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+}
+
+func TestFeedETag(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, header, _ := ts.Get(t, "/feed.atom")
+	assert.Equal(t, status, http.StatusOK)
+	etag := header.Get("ETag")
+	assert.Equal(t, etag != "", true)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "Same version", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "Weak validator", ifNoneMatch: `"stale", W/` + etag, wantStatus: http.StatusNotModified},
+		{name: "Other version", ifNoneMatch: `"stale"`, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/feed.atom", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			assert.Equal(t, res.StatusCode, tt.wantStatus)
+			assert.Equal(t, res.Header.Get("ETag"), etag)
+		})
+	}
+}
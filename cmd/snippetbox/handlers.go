@@ -1,11 +1,19 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/huytran2000-hcmus/snippetbox/internal/highlight"
 	"github.com/huytran2000-hcmus/snippetbox/internal/models"
 	"github.com/huytran2000-hcmus/snippetbox/internal/validator"
 	"github.com/julienschmidt/httprouter"
@@ -19,9 +27,39 @@ const (
 
 type snippetCreateForm struct {
 	validator.Validator `form:"-"`
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             string `form:"expires"`
+	Title               string            `form:"title"`
+	Files               []snippetFileForm `form:"files"`
+	Expires             string            `form:"expires"`
+	ExpiresAt           string            `form:"expires_at"`
+	Visibility          string            `form:"visibility"`
+	Tags                string            `form:"tags"`
+	Password            string            `form:"password"`
+	RemovePassword      bool              `form:"remove_password"`
+	// AddFile and RemoveFile are set by the buttons adding a file row and
+	// removing the file row at the given index.
+	AddFile    bool   `form:"add_file"`
+	RemoveFile string `form:"remove_file"`
+}
+
+type snippetFileForm struct {
+	Name     string `form:"name"`
+	Language string `form:"language"`
+	Content  string `form:"content"`
+}
+
+type commentForm struct {
+	validator.Validator `form:"-"`
+	Body                string `form:"body"`
+	// ParentID is the comment replied to, 0 for a top level comment.
+	ParentID int `form:"parent_id"`
+}
+
+// maxCommentLength is how many characters a comment can have.
+const maxCommentLength = 2000
+
+type snippetUnlockForm struct {
+	validator.Validator `form:"-"`
+	Password            string `form:"password"`
 }
 
 type userSignupForm struct {
@@ -44,102 +82,1178 @@ type accountPasswordUpdateForm struct {
 	ConfirmedNewPassword string `form:"confirmed_new_password"`
 }
 
-func (app *Application) home(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		app.notFound(w)
+type tokenForm struct {
+	validator.Validator `form:"-"`
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scopes"`
+	Expires             string   `form:"expires"`
+}
+
+type webhookForm struct {
+	validator.Validator `form:"-"`
+	URL                 string   `form:"url"`
+	Secret              string   `form:"secret"`
+	Events              []string `form:"events"`
+}
+
+func (app *Application) home(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		app.notFound(w, r)
+		return
+	}
+
+	var v validator.Validator
+	p := readPagination(r, &v)
+	if !v.IsValid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, total, err := app.snippet.List(p.Page, p.PageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+
+	var text strings.Builder
+	for _, s := range snippets {
+		fmt.Fprintf(&text, "%s/s/%s\t%s\n", app.publicURL, s.Slug, s.Title)
+	}
+
+	app.respond(w, r, http.StatusOK, &representation{
+		Page: "home",
+		Data: func() (*templateData, error) {
+			mostStarred, err := app.snippet.MostStarred(time.Now().Add(-mostStarredPeriod), mostStarredSize)
+			if err != nil {
+				return nil, err
+			}
+
+			data := app.newDefaultTemplateData(r)
+			data.Snippets = snippets
+			data.MostStarred = mostStarred
+			data.Pagination = p
+			return data, nil
+		},
+		JSON: app.newAPISnippetList(snippets, p),
+		Text: text.String(),
+	})
+}
+
+func (app *Application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	tag := params.ByName("name")
+	if !tagRX.MatchString(tag) {
+		app.notFound(w, r)
+		return
+	}
+
+	var v validator.Validator
+	p := readPagination(r, &v)
+	if !v.IsValid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, total, err := app.snippet.ByTag(tag, p.Page, p.PageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+
+	data := app.newDefaultTemplateData(r)
+	data.Tag = tag
+	data.Snippets = snippets
+	data.Pagination = p
+	app.render(w, r, http.StatusOK, "tag", data)
+}
+
+func (app *Application) tags(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.snippet.TagCounts(tagCloudSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.TagCloud = newTagCloud(counts)
+	app.render(w, r, http.StatusOK, "tags", data)
+}
+
+func (app *Application) snippetView(w http.ResponseWriter, r *http.Request) {
+	// Checked before reading, so a burn-after-reading snippet isn't burnt
+	// for a response the client doesn't accept.
+	if negotiate(r) == "" {
+		app.clientError(w, r, http.StatusNotAcceptable)
+		return
+	}
+
+	s, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Authors reading their own snippets don't count as views.
+	if s.UserID != app.authenticatedUserID(r) {
+		app.viewCounter.Add(s.ID, clientIP(r), referrerHost(r.Referer(), r.Host))
+	}
+
+	app.respond(w, r, http.StatusOK, &representation{
+		Page: "view",
+		Data: func() (*templateData, error) {
+			return app.snippetTemplateData(r, s, &commentForm{})
+		},
+		JSON: app.newAPISnippet(s),
+		Text: snippetText(s),
+	})
+}
+
+// snippetStats shows the author of a snippet how often it has been read.
+func (app *Application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -viewStatsDays+1)
+	stats, err := app.views.Stats(s.ID, since, viewStatsReferrers)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Snippet = s
+	data.ViewStats = stats
+	app.render(w, r, http.StatusOK, "stats", data)
+}
+
+// renderSnippet renders the view page of s along with its comments. form is
+// the comment form.
+func (app *Application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *commentForm, status int) {
+	data, err := app.snippetTemplateData(r, s, form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.render(w, r, status, "view", data)
+}
+
+// snippetTemplateData returns the template data of the view page of s.
+func (app *Application) snippetTemplateData(r *http.Request, s *models.Snippet, form *commentForm) (*templateData, error) {
+	data := app.newDefaultTemplateData(r)
+	data.Snippet = s
+	data.Form = form
+
+	if data.IsAuthenticated {
+		starred, err := app.stars.Starred(data.AuthenticatedID, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Starred = starred
+	}
+
+	// Burn-after-reading snippets are gone once read, along with their
+	// comments, so they aren't commented on.
+	if !s.BurnAfterReading {
+		comments, err := app.comments.BySnippet(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Comments = threadComments(comments)
+	}
+
+	return data, nil
+}
+
+// snippetText is the plain text form of a snippet: its content, or each of
+// its files under a header naming it when it has several.
+func snippetText(s *models.Snippet) string {
+	if len(s.Files) <= 1 {
+		return s.Content
+	}
+
+	var b strings.Builder
+	for i, f := range s.Files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "==> %s <==\n%s\n", f.Name, strings.TrimSuffix(f.Content, "\n"))
+	}
+
+	return b.String()
+}
+
+// snippetStar stars the snippet for the authenticated user, or removes their
+// star if they already starred it.
+func (app *Application) snippetStar(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	starred, err := app.stars.Toggle(app.authenticatedUserID(r), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if starred {
+		app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been starred!")
+	} else {
+		app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been unstarred!")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", s.Slug), http.StatusSeeOther)
+}
+
+func (app *Application) commentCreate(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if s.BurnAfterReading {
+		app.notFound(w, r)
+		return
+	}
+
+	var form commentForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	body := form.CheckField("body", form.Body).
+		NotBlank("This field can't be blank").
+		LE(fmt.Sprintf("This field can't be more than %d characters long", maxCommentLength), maxCommentLength).
+		Value()
+	if !form.IsValid() {
+		app.renderSnippet(w, r, s, &form, http.StatusUnprocessableEntity)
+		return
+	}
+
+	c := &models.Comment{
+		SnippetID: s.ID,
+		ParentID:  form.ParentID,
+		UserID:    app.authenticatedUserID(r),
+		Body:      body,
+	}
+	err = app.comments.Insert(c)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Comment has been successfully posted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s#comment-%d", s.Slug, c.ID), http.StatusSeeOther)
+}
+
+// commentDelete deletes a comment, which its author and the author of the
+// snippet it's on are allowed to do.
+func (app *Application) commentDelete(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	c, err := app.comments.Get(id)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	s, err := app.snippet.Get(c.SnippetID)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	if userID != c.UserID && userID != s.UserID {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	err = app.comments.Delete(c.ID)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Comment has been successfully deleted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s#comments", s.Slug), http.StatusSeeOther)
+}
+
+func (app *Application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	content := s.Content
+	if name := r.URL.Query().Get("file"); name != "" {
+		f, ok := snippetFile(s, name)
+		if !ok {
+			app.notFound(w, r)
+			return
+		}
+		content = f.Content
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, content)
+}
+
+// snippetFile returns the file of s with the given name.
+func snippetFile(s *models.Snippet, name string) (models.SnippetFile, bool) {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return models.SnippetFile{}, false
+}
+
+func (app *Application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if len(s.Files) <= 1 {
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": downloadFileName(s)})
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", disposition)
+		io.WriteString(w, s.Content)
+		return
+	}
+
+	// The archive is built in memory first, so a failure can still be
+	// reported with a proper status.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range s.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: s.Created})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		_, err = io.WriteString(fw, f.Content)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": downloadBaseName(s) + ".zip"})
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", disposition)
+	buf.WriteTo(w)
+}
+
+// maxFileNameLength caps the part of download file names taken from titles.
+const maxFileNameLength = 64
+
+// downloadFileName builds a file name from the title of s followed by the
+// extension of its language.
+func downloadFileName(s *models.Snippet) string {
+	extension := "txt"
+	if language, ok := highlight.Lookup(s.Language); ok {
+		extension = language.Extension
+	}
+
+	return downloadBaseName(s) + "." + extension
+}
+
+// downloadBaseName builds a file name without extension from the title of s,
+// keeping ASCII letters and digits and turning everything else into dashes.
+func downloadBaseName(s *models.Snippet) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(s.Title) {
+		if b.Len() >= maxFileNameLength {
+			break
+		}
+
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	name := strings.TrimSuffix(b.String(), "-")
+	if name == "" {
+		name = "snippet-" + s.Slug
+	}
+
+	return name
+}
+
+func (app *Application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippet.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Snippet = s
+	data.Revisions = revisions
+	app.render(w, r, http.StatusOK, "history", data)
+}
+
+func (app *Application) snippetRevision(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	n, err := strconv.Atoi(params.ByName("n"))
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	revision, err := app.snippet.Revision(s.ID, n)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Snippet = s
+	data.Revision = revision
+	app.render(w, r, http.StatusOK, "revision", data)
+}
+
+func (app *Application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippet.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFound(w, r)
+		return
+	}
+
+	from, to := len(revisions)-1, len(revisions)
+	if from < 1 {
+		from = 1
+	}
+
+	var v validator.Validator
+	query := r.URL.Query()
+	if query.Has("from") {
+		from = v.CheckField("from", query.Get("from")).
+			IntBetween("This parameter must be an existing revision", 1, len(revisions)).
+			ToInt("This parameter must be an existing revision")
+	}
+	if query.Has("to") {
+		to = v.CheckField("to", query.Get("to")).
+			IntBetween("This parameter must be an existing revision", 1, len(revisions)).
+			ToInt("This parameter must be an existing revision")
+	}
+	if !v.IsValid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	diff, err := unifiedDiff(&revisions[from-1], &revisions[to-1])
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Snippet = s
+	data.Revisions = revisions
+	data.DiffFrom = &revisions[from-1]
+	data.DiffTo = &revisions[to-1]
+	data.Diff = diff
+	app.render(w, r, http.StatusOK, "diff", data)
+}
+
+func (app *Application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+	p := readPagination(r, &v)
+	query := v.CheckField("q", r.URL.Query().Get("q")).
+		LE("The search query can't be more than 200 characters long", 200).
+		Value()
+	if !v.IsValid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Query = query
+
+	if strings.TrimSpace(query) != "" {
+		results, total, err := app.snippet.Search(query, p.Page, p.PageSize)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		p.Total = total
+
+		data.SearchResults = results
+		data.Pagination = p
+	}
+
+	app.render(w, r, http.StatusOK, "search", data)
+}
+
+func (app *Application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if form.editFiles() {
+		data := app.newDefaultTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusOK, "create", data)
+		return
+	}
+
+	s := form.validate(nil)
+	if !form.IsValid() {
+		data := app.newDefaultTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "create", data)
+		return
+	}
+
+	s.UserID = app.authenticatedUserID(r)
+	err = app.snippet.Insert(s)
+	if errors.Is(err, models.ErrPasswordTooLong) {
+		form.AddFieldError("password", "The password is too long")
+		data := app.newDefaultTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "create", data)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", s.Slug), http.StatusSeeOther)
+}
+
+func (app *Application) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
+	data := app.newDefaultTemplateData(r)
+	data.Form = &snippetCreateForm{
+		Files:      []snippetFileForm{{Language: highlight.PlainText}},
+		Expires:    defaultExpiry,
+		Visibility: models.VisibilityPublic,
+	}
+	app.render(w, r, http.StatusOK, "create", data)
+}
+
+func (app *Application) snippetEditForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Snippet = s
+	data.Form = &snippetCreateForm{
+		Title:      s.Title,
+		Files:      newSnippetFileForms(s.Files),
+		Expires:    expiryKeep,
+		Visibility: s.Visibility,
+		Tags:       strings.Join(s.Tags, ", "),
+	}
+	app.render(w, r, http.StatusOK, "edit", data)
+}
+
+func (app *Application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	if form.editFiles() {
+		data := app.newDefaultTemplateData(r)
+		data.Snippet = s
+		data.Form = form
+		app.render(w, r, http.StatusOK, "edit", data)
+		return
+	}
+
+	updated := form.validate(s)
+	if !form.IsValid() {
+		data := app.newDefaultTemplateData(r)
+		data.Snippet = s
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit", data)
+		return
+	}
+
+	updated.ID = s.ID
+	updated.Slug = s.Slug
+	updated.UserID = s.UserID
+	err = app.snippet.Update(updated, app.authenticatedUserID(r))
+	if errors.Is(err, models.ErrPasswordTooLong) {
+		form.AddFieldError("password", "The password is too long")
+		data := app.newDefaultTemplateData(r)
+		data.Snippet = s
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit", data)
+		return
+	}
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", s.Slug), http.StatusSeeOther)
+}
+
+func (app *Application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	fork := &models.Snippet{
+		Title:      s.Title,
+		Files:      s.Files,
+		Expires:    time.Now().Add(expiryDurations[defaultExpiry]),
+		UserID:     app.authenticatedUserID(r),
+		Visibility: s.Visibility,
+		Tags:       s.Tags,
+		ForkedFrom: s.ID,
+	}
+	// The fork doesn't get the password, so it's kept private rather than
+	// listing the content the password protects.
+	if s.Protected {
+		fork.Visibility = models.VisibilityPrivate
+	}
+	err := app.snippet.Insert(fork)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been successfully forked!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/edit/%s", fork.Slug), http.StatusSeeOther)
+}
+
+func (app *Application) snippetDelete(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
 		return
 	}
 
-	snippets, err := app.snippet.Latest()
+	err := app.snippet.Delete(s.ID)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	data := app.newDefaultTemplateData(r)
-	data.Snippets = snippets
-	app.render(w, http.StatusOK, "home", data)
+	app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been successfully deleted!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
-func (app *Application) snippetView(w http.ResponseWriter, r *http.Request) {
+// findSnippet loads the snippet named by the slug route parameter, or by the
+// id route parameter which holds either a numeric ID or a slug. Snippets the
+// current user isn't allowed to read are reported as not found, so their
+// existence isn't leaked. It writes the error response itself and reports
+// false when the handler should stop.
+func (app *Application) findSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, err := app.getSnippet(r)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return nil, false
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// getSnippet is like findSnippet but returns models.ErrNoRecord instead of
+// writing the not found response.
+func (app *Application) getSnippet(r *http.Request) (*models.Snippet, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	id, err := strconv.Atoi(params.ByName("id"))
+	key := params.ByName("slug")
+	if key == "" {
+		key = params.ByName("id")
+	}
+
+	var s *models.Snippet
+	var err error
+	if id, atoiErr := strconv.Atoi(key); atoiErr == nil {
+		if !app.idAccess || id < 1 {
+			return nil, models.ErrNoRecord
+		}
+		s, err = app.snippet.Get(id)
+		// Only the slug of unlisted, private and password protected
+		// snippets leads to them.
+		if err == nil && (s.Visibility != models.VisibilityPublic || s.Protected) {
+			return nil, models.ErrNoRecord
+		}
+	} else {
+		s, err = app.snippet.GetBySlug(key)
+	}
 	if err != nil {
-		app.clientError(w, http.StatusNotFound)
-		return
+		return nil, err
+	}
+
+	if !s.VisibleTo(app.authenticatedUserID(r)) {
+		return nil, models.ErrNoRecord
+	}
+
+	return s, nil
+}
+
+// lookupSnippet is like findSnippet but also reports burn-after-reading
+// snippets of other users as not found, as only reading them is allowed.
+func (app *Application) lookupSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if s.BurnAfterReading && s.UserID != app.authenticatedUserID(r) {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	if !app.isUnlocked(r, s) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", s.Slug), http.StatusSeeOther)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// readSnippet is like findSnippet but burns burn-after-reading snippets of
+// other users, so the returned snippet can't be read again. Locked snippets
+// get the unlock form instead.
+func (app *Application) readSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !app.isUnlocked(r, s) {
+		// Only browsers can fill in the unlock form.
+		if negotiate(r) != mediaHTML {
+			app.clientError(w, r, http.StatusForbidden)
+			return nil, false
+		}
+		app.renderUnlock(w, r, s, &snippetUnlockForm{}, http.StatusForbidden)
+		return nil, false
 	}
 
-	s, err := app.snippet.Get(id)
+	if !s.BurnAfterReading || s.UserID == app.authenticatedUserID(r) {
+		return s, true
+	}
+
+	s, err := app.snippet.Burn(s.ID)
 	if errors.Is(err, models.ErrNoRecord) {
-		app.notFound(w)
-		return
+		app.notFound(w, r)
+		return nil, false
 	}
 	if err != nil {
-		app.serverError(w, err)
-		return
+		app.serverError(w, r, err)
+		return nil, false
 	}
 
-	data := app.newDefaultTemplateData(r)
-	data.Snippet = s
-	app.render(w, http.StatusOK, "view", data)
+	return s, true
 }
 
-func (app *Application) snippetCreate(w http.ResponseWriter, r *http.Request) {
-	var form snippetCreateForm
+// ownedSnippet is like lookupSnippet but also makes sure the snippet belongs
+// to the authenticated user.
+func (app *Application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.lookupSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if s.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, r, http.StatusForbidden)
+		return nil, false
+	}
+
+	return s, true
+}
+
+// isUnlocked reports whether the current user may read s, which is always the
+// case for its author and for snippets without a password.
+func (app *Application) isUnlocked(r *http.Request, s *models.Snippet) bool {
+	if !s.Protected || s.UserID == app.authenticatedUserID(r) {
+		return true
+	}
+
+	return app.sessionManager.GetBool(r.Context(), unlockedSnippetKey(s.ID))
+}
+
+// unlockedSnippetKey is the session key remembering that the snippet with
+// the given ID has been unlocked.
+func unlockedSnippetKey(id int) string {
+	return fmt.Sprintf("unlockedSnippet:%d", id)
+}
+
+func (app *Application) snippetUnlock(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if app.isUnlocked(r, s) {
+		http.Redirect(w, r, fmt.Sprintf("/s/%s", s.Slug), http.StatusSeeOther)
+		return
+	}
+
+	var form snippetUnlockForm
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	title := form.CheckField("title", form.Title).
-		NotBlank("This field can't be blank").
-		LE("This field can't be more than 100 characters long", 100).Value()
-	content := form.CheckField("content", form.Content).
-		NotBlank("This field can't be blank").Value()
-	expires := form.CheckField("expires", form.Expires).
-		In("This field must be equal 7, 30 or 365", "7", "30", "365").
-		ToInt("This field must be a number equal 7, 30 or 365")
+	// Attempts are limited per client and snippet, so guessing one password
+	// is slow while a client locked out of one snippet can still open others.
+	attemptKey := fmt.Sprintf("%s:%d", clientIP(r), s.ID)
+	if !app.unlockLimiter.Allow(attemptKey) {
+		form.AddNonFieldError("Too many failed attempts. Please try again later")
+		app.renderUnlock(w, r, s, &form, http.StatusTooManyRequests)
+		return
+	}
 
+	password := form.CheckField("password", form.Password).
+		NotBlank("This field can't be blank").
+		Value()
 	if !form.IsValid() {
-		data := app.newDefaultTemplateData(r)
-		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create", data)
+		app.renderUnlock(w, r, s, &form, http.StatusUnprocessableEntity)
 		return
 	}
 
-	id, err := app.snippet.Insert(title, content, expires)
+	err = app.snippet.Unlock(s.ID, password)
+	if errors.Is(err, models.ErrInvalidCredentials) {
+		app.unlockLimiter.Fail(attemptKey)
+		form.AddFieldError("password", "The password is incorrect")
+		app.renderUnlock(w, r, s, &form, http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), flashMessKey, "Snippet has been successfully created!")
+	app.unlockLimiter.Reset(attemptKey)
+	app.sessionManager.Put(r.Context(), unlockedSnippetKey(s.ID), true)
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/s/%s", s.Slug), http.StatusSeeOther)
 }
 
-func (app *Application) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
+func (app *Application) renderUnlock(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *snippetUnlockForm, status int) {
 	data := app.newDefaultTemplateData(r)
-	data.Form = &snippetCreateForm{
-		Expires: "365",
+	data.Snippet = s
+	data.Form = form
+	app.render(w, r, status, "unlock", data)
+}
+
+const (
+	maxTags      = 5
+	maxTagLength = 32
+	// tagCloudSize is how many of the most used tags the tag cloud shows.
+	tagCloudSize = 50
+)
+
+var tagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9.+-]*$`)
+
+const (
+	// mostStarredSize is how many snippets the most starred list on the home
+	// page shows, counting the stars they got over mostStarredPeriod.
+	mostStarredSize   = 5
+	mostStarredPeriod = 7 * 24 * time.Hour
+)
+
+// maxFiles is how many files a snippet can have.
+const maxFiles = 10
+
+var fileNameRX = regexp.MustCompile(`^[A-Za-z0-9_+-][A-Za-z0-9_. +-]*$`)
+
+const (
+	expiryNever  = "never"
+	expiryBurn   = "burn"
+	expiryCustom = "custom"
+	// expiryKeep keeps the expiry of an edited snippet unchanged.
+	expiryKeep = "keep"
+	// defaultExpiry is the expiry new snippets get unless another is
+	// picked, and the one forks get.
+	defaultExpiry = "365d"
+
+	expiresAtLayout = "2006-01-02T15:04"
+	// burnExpiry is how long an unread burn-after-reading snippet is kept.
+	burnExpiry = 7 * 24 * time.Hour
+)
+
+var expiryDurations = map[string]time.Duration{
+	"10m":  10 * time.Minute,
+	"1h":   time.Hour,
+	"1d":   24 * time.Hour,
+	"7d":   7 * 24 * time.Hour,
+	"30d":  30 * 24 * time.Hour,
+	"365d": 365 * 24 * time.Hour,
+}
+
+// tokenExpiryDurations are how long personal access tokens can last, besides
+// never expiring.
+var tokenExpiryDurations = map[string]time.Duration{
+	"30d":  30 * 24 * time.Hour,
+	"90d":  90 * 24 * time.Hour,
+	"365d": 365 * 24 * time.Hour,
+}
+
+// validate checks the form and returns the snippet it describes. current is
+// the snippet being edited, nil when creating one. The result is only
+// meaningful when the form is valid.
+func (form *snippetCreateForm) validate(current *models.Snippet) *models.Snippet {
+	title := form.CheckField("title", form.Title).
+		NotBlank("This field can't be blank").
+		LE("This field can't be more than 100 characters long", 100).Value()
+	files := form.validateFiles()
+	visibility := form.CheckField("visibility", form.Visibility).
+		In("This field must be public, unlisted or private", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate).
+		Value()
+	tags := form.CheckField("tags", strings.ToLower(form.Tags)).
+		Split(",").
+		MaxItems(fmt.Sprintf("This field can't have more than %d tags", maxTags), maxTags).
+		ItemsLE(fmt.Sprintf("Each tag can't be more than %d characters long", maxTagLength), maxTagLength).
+		ItemsMatch("Each tag must only contain letters, digits, dashes, dots and pluses, starting with a letter or digit", tagRX).
+		Items()
+	var password string
+	if form.Password != "" {
+		password = form.CheckField("password", form.Password).
+			GE("This field must be at least 8 characters long", 8).
+			Value()
+	}
+
+	s := &models.Snippet{
+		Title:      title,
+		Files:      files,
+		Visibility: visibility,
+		Tags:       tags,
+		Password:   password,
+		Protected:  password != "" || current != nil && current.Protected && !form.RemovePassword,
+	}
+
+	now := time.Now()
+	switch form.Expires {
+	case expiryNever:
+	case expiryBurn:
+		s.Expires = now.Add(burnExpiry)
+		s.BurnAfterReading = true
+	case expiryCustom:
+		expiresAt := form.CheckField("expires_at", form.ExpiresAt).
+			NotBlank("This field can't be blank when expiring at a custom date").
+			ToTime("This field must be a date and time", expiresAtLayout, time.UTC)
+		if _, invalid := form.FieldErrs["expires_at"]; !invalid && !expiresAt.After(now) {
+			form.AddFieldError("expires_at", "This field must be in the future")
+		}
+		s.Expires = expiresAt
+	case expiryKeep:
+		if current == nil {
+			form.AddFieldError("expires", "This field must be one of the listed options")
+			break
+		}
+		s.Expires = current.Expires
+		s.BurnAfterReading = current.BurnAfterReading
+	default:
+		d, ok := expiryDurations[form.Expires]
+		if !ok {
+			form.AddFieldError("expires", "This field must be one of the listed options")
+		}
+		s.Expires = now.Add(d)
+	}
+
+	return s
+}
+
+// validateFiles checks the file rows of the form and returns the files they
+// describe. Files left unnamed are named after their position and language.
+func (form *snippetCreateForm) validateFiles() []models.SnippetFile {
+	if len(form.Files) == 0 {
+		form.Files = []snippetFileForm{{}}
+	}
+	if len(form.Files) > maxFiles {
+		form.AddFieldError("files", fmt.Sprintf("A snippet can't have more than %d files", maxFiles))
+	}
+
+	files := make([]models.SnippetFile, len(form.Files))
+	names := map[string]bool{}
+	for i, f := range form.Files {
+		field := fmt.Sprintf("files[%d].", i)
+		name := form.CheckField(field+"name", strings.TrimSpace(f.Name)).
+			LE("This field can't be more than 100 characters long", 100).
+			Value()
+		if name != "" {
+			form.Matches("This field must only contain letters, digits, spaces, dots, dashes, underscores and pluses, not starting with a dot or a space", fileNameRX)
+		}
+		language := form.CheckField(field+"language", f.Language).
+			In("This field must be one of the listed languages", highlight.Names()...).
+			Value()
+		content := form.CheckField(field+"content", f.Content).
+			NotBlank("This field can't be blank").
+			Value()
+
+		if name == "" {
+			name = defaultFileName(i, language)
+		}
+		if names[name] {
+			form.AddFieldError(field+"name", "Another file already has this name")
+		}
+		names[name] = true
+
+		files[i] = models.SnippetFile{Name: name, Language: language, Content: content}
+	}
+
+	return files
+}
+
+// editFiles adds or removes a file row when the form was submitted by one of
+// the file row buttons, and reports whether it was, in which case the form
+// should be shown again instead of being saved.
+func (form *snippetCreateForm) editFiles() bool {
+	switch {
+	case form.AddFile:
+		if len(form.Files) >= maxFiles {
+			form.AddFieldError("files", fmt.Sprintf("A snippet can't have more than %d files", maxFiles))
+			return true
+		}
+
+		form.Files = append(form.Files, snippetFileForm{Language: highlight.PlainText})
+		return true
+	case form.RemoveFile != "":
+		i, err := strconv.Atoi(form.RemoveFile)
+		if err == nil && i >= 0 && i < len(form.Files) && len(form.Files) > 1 {
+			form.Files = append(form.Files[:i], form.Files[i+1:]...)
+		}
+
+		return true
+	default:
+		return false
+	}
+}
+
+func newSnippetFileForms(files []models.SnippetFile) []snippetFileForm {
+	forms := make([]snippetFileForm, len(files))
+	for i, f := range files {
+		forms[i] = snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content}
+	}
+
+	return forms
+}
+
+// defaultFileName names the i-th file of a snippet after its position and
+// language.
+func defaultFileName(i int, language string) string {
+	extension := "txt"
+	if l, ok := highlight.Lookup(language); ok {
+		extension = l.Extension
 	}
-	app.render(w, http.StatusOK, "create", data)
+
+	return fmt.Sprintf("file%d.%s", i+1, extension)
 }
 
 func (app *Application) userSignupForm(w http.ResponseWriter, r *http.Request) {
 	data := app.newDefaultTemplateData(r)
 	data.Form = &userSignupForm{}
 
-	app.render(w, http.StatusOK, "signup", data)
+	app.render(w, r, http.StatusOK, "signup", data)
 }
 
 func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 	var form userSignupForm
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -160,7 +1274,7 @@ func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 	renderFormErrors := func() {
 		data := app.newDefaultTemplateData(r)
 		data.Form = &form
-		app.render(w, http.StatusUnprocessableEntity, "signup", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup", data)
 	}
 
 	if !form.IsValid() {
@@ -182,7 +1296,7 @@ func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -193,14 +1307,14 @@ func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 func (app *Application) userLoginForm(w http.ResponseWriter, r *http.Request) {
 	data := app.newDefaultTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login", data)
+	app.render(w, r, http.StatusOK, "login", data)
 }
 
 func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 	form := userLoginForm{}
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -217,7 +1331,7 @@ func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 	renderFormErrors := func() {
 		data := app.newDefaultTemplateData(r)
 		data.Form = &form
-		app.render(w, http.StatusBadRequest, "login", data)
+		app.render(w, r, http.StatusBadRequest, "login", data)
 	}
 	if !form.IsValid() {
 		renderFormErrors()
@@ -232,13 +1346,13 @@ func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 	app.sessionManager.Put(r.Context(), userIDKey, id)
@@ -256,7 +1370,7 @@ func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 func (app *Application) userLogout(w http.ResponseWriter, r *http.Request) {
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -272,7 +1386,7 @@ func ping(w http.ResponseWriter, r *http.Request) {
 
 func (app *Application) about(w http.ResponseWriter, r *http.Request) {
 	data := app.newDefaultTemplateData(r)
-	app.render(w, http.StatusOK, "about", data)
+	app.render(w, r, http.StatusOK, "about", data)
 }
 
 func (app *Application) account(w http.ResponseWriter, r *http.Request) {
@@ -285,26 +1399,239 @@ func (app *Application) account(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets, err := app.snippet.ByUser(userID)
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
 	data := app.newDefaultTemplateData(r)
 	data.User = user
-	app.render(w, http.StatusOK, "account", data)
+	data.Snippets = snippets
+	app.render(w, r, http.StatusOK, "account", data)
+}
+
+func (app *Application) accountStarred(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+	p := readPagination(r, &v)
+	if !v.IsValid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, total, err := app.snippet.StarredBy(app.authenticatedUserID(r), p.Page, p.PageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	p.Total = total
+
+	data := app.newDefaultTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = p
+	app.render(w, r, http.StatusOK, "starred", data)
+}
+
+func (app *Application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, &tokenForm{Expires: "90d"}, "", http.StatusOK)
+}
+
+// renderTokens renders the tokens page with the token creation form. token
+// is the plaintext of a token just created, shown only this once.
+func (app *Application) renderTokens(w http.ResponseWriter, r *http.Request, form *tokenForm, token string, status int) {
+	tokens, err := app.tokens.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Tokens = tokens
+	data.NewToken = token
+	data.Form = form
+	app.render(w, r, status, "tokens", data)
+}
+
+func (app *Application) accountTokenCreate(w http.ResponseWriter, r *http.Request) {
+	var form tokenForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	name := form.CheckField("name", strings.TrimSpace(form.Name)).
+		NotBlank("This field can't be blank").
+		LE("This field can't be more than 100 characters long", 100).
+		Value()
+	scopes := form.CheckField("scopes", strings.Join(form.Scopes, ",")).
+		Split(",").
+		MinItems("Pick at least one scope", 1).
+		ItemsIn("This field must only hold the listed scopes", models.ScopeSnippetsRead, models.ScopeSnippetsWrite).
+		Items()
+	_, ok := tokenExpiryDurations[form.Expires]
+	if !ok && form.Expires != expiryNever {
+		form.AddFieldError("expires", "This field must be one of the listed options")
+	}
+	if !form.IsValid() {
+		app.renderTokens(w, r, &form, "", http.StatusUnprocessableEntity)
+		return
+	}
+
+	t := &models.Token{
+		UserID: app.authenticatedUserID(r),
+		Name:   name,
+		Scopes: scopes,
+	}
+	if form.Expires != expiryNever {
+		t.Expires = time.Now().Add(tokenExpiryDurations[form.Expires])
+	}
+	plaintext, err := app.tokens.Insert(t)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The page shows the token in plain text, so it must not be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	app.renderTokens(w, r, &tokenForm{Expires: "90d"}, plaintext, http.StatusCreated)
+}
+
+func (app *Application) accountTokenRevoke(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.tokens.Delete(id, app.authenticatedUserID(r))
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Token has been successfully revoked!")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (app *Application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	app.renderWebhooks(w, r, &webhookForm{Events: []string{models.EventSnippetCreated}}, http.StatusOK)
+}
+
+// renderWebhooks renders the webhooks page with the latest deliveries and the
+// form adding a webhook.
+func (app *Application) renderWebhooks(w http.ResponseWriter, r *http.Request, form *webhookForm, status int) {
+	userID := app.authenticatedUserID(r)
+	webhooks, err := app.webhooks.ByUser(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	attempts, err := app.webhooks.Attempts(userID, webhookLogSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newDefaultTemplateData(r)
+	data.Webhooks = webhooks
+	data.Attempts = attempts
+	data.Form = form
+	app.render(w, r, status, "webhooks", data)
+}
+
+func (app *Application) accountWebhookCreate(w http.ResponseWriter, r *http.Request) {
+	var form webhookForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	webhookURL := form.CheckField("url", strings.TrimSpace(form.URL)).
+		NotBlank("This field can't be blank").
+		LE("This field can't be more than 2048 characters long", 2048).
+		IsURL("This field must be an http or https URL", "http", "https").
+		Value()
+	secret := form.CheckField("secret", form.Secret).
+		GE("This field must be at least 16 characters long", 16).
+		LE("This field can't be more than 255 characters long", 255).
+		Value()
+	events := form.CheckField("events", strings.Join(form.Events, ",")).
+		Split(",").
+		MinItems("Pick at least one event", 1).
+		ItemsIn("This field must only hold the listed events", models.EventSnippetCreated, models.EventSnippetExpired).
+		Items()
+	if isInternalURL(webhookURL) {
+		form.AddFieldError("url", "This field can't point to an internal address")
+	}
+	if !form.IsValid() {
+		form.Secret = ""
+		app.renderWebhooks(w, r, &form, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = app.webhooks.Insert(&models.Webhook{
+		UserID: app.authenticatedUserID(r),
+		URL:    webhookURL,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Webhook has been successfully added!")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+func (app *Application) accountWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.webhooks.Delete(id, app.authenticatedUserID(r))
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), flashMessKey, "Webhook has been successfully deleted!")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
 }
 
 func (app *Application) accountPasswordUpdateForm(w http.ResponseWriter, r *http.Request) {
 	data := app.newDefaultTemplateData(r)
 	data.Form = accountPasswordUpdateForm{}
-	app.render(w, http.StatusOK, "change_password", data)
+	app.render(w, r, http.StatusOK, "change_password", data)
 }
 
 func (app *Application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
 	var form accountPasswordUpdateForm
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 	}
 
 	currentPassword := form.CheckField("current_password", form.CurrentPassword).
@@ -323,7 +1650,7 @@ func (app *Application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 	renderFormErrors := func() {
 		data := app.newDefaultTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "change_password", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "change_password", data)
 	}
 
 	if !form.IsValid() {
@@ -346,7 +1673,7 @@ func (app *Application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 			return
 		}
 
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -355,7 +1682,7 @@ func (app *Application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"archive/zip"
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+	"github.com/huytran2000-hcmus/snippetbox/internal/mock"
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
 )
 
 func TestPing(t *testing.T) {
@@ -21,8 +28,214 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, string(body), "OK")
 }
 
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Default page",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Most starred",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "Most Starred This Week",
+		},
+		{
+			name:     "Page out of range",
+			urlPath:  "/?page=2&page_size=1",
+			wantCode: http.StatusOK,
+			wantBody: "There's nothing to see here... yet!",
+		},
+		{
+			name:     "Zero page",
+			urlPath:  "/?page=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "String page",
+			urlPath:  "/?page=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Page size too large",
+			urlPath:  "/?page_size=101",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.Get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		accept     string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{
+			name:       "Home as HTML",
+			urlPath:    "/",
+			wantStatus: http.StatusOK,
+			wantType:   "text/html; charset=utf-8",
+			wantBody:   "<h2>Latest Snippets <a",
+		},
+		{
+			name:       "Home as JSON",
+			urlPath:    "/",
+			accept:     "application/json",
+			wantStatus: http.StatusOK,
+			wantType:   "application/json",
+			wantBody:   `"slug":"pondXy12Ab"`,
+		},
+		{
+			name:       "Home as plain text",
+			urlPath:    "/",
+			accept:     "text/plain",
+			wantStatus: http.StatusOK,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   app.publicURL + "/s/pondXy12Ab\tAn old silent pond",
+		},
+		{
+			name:       "Snippet as JSON",
+			urlPath:    "/snippet/view/pondXy12Ab",
+			accept:     "application/json",
+			wantStatus: http.StatusOK,
+			wantType:   "application/json",
+			wantBody:   `"content":"An old silent pond...`,
+		},
+		{
+			name:       "Snippet as plain text",
+			urlPath:    "/s/pondXy12Ab",
+			accept:     "text/plain",
+			wantStatus: http.StatusOK,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "An old silent pond...",
+		},
+		{
+			name:       "Snippet with files as plain text",
+			urlPath:    "/s/bundleQ7Rt",
+			accept:     "text/plain",
+			wantStatus: http.StatusOK,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "==> main.go <==\n",
+		},
+		{
+			name:       "Locked snippet as JSON",
+			urlPath:    "/s/lockedZ9Kq",
+			accept:     "application/json",
+			wantStatus: http.StatusForbidden,
+			wantType:   "application/json",
+			wantBody:   `{"error":"Forbidden"}`,
+		},
+		{
+			name:       "Not found as HTML",
+			urlPath:    "/s/missing000",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/html; charset=utf-8",
+			wantBody:   "<h2>404 Not Found</h2>",
+		},
+		{
+			name:       "Not found as JSON",
+			urlPath:    "/s/missing000",
+			accept:     "application/json",
+			wantStatus: http.StatusNotFound,
+			wantType:   "application/json",
+			wantBody:   `{"error":"Not Found"}`,
+		},
+		{
+			name:       "Not found as plain text",
+			urlPath:    "/no/such/page",
+			accept:     "text/plain",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Found",
+		},
+		{
+			name:       "Raw not found for curl",
+			urlPath:    "/snippet/raw/missing000",
+			accept:     "*/*",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Found",
+		},
+		{
+			name:       "Raw not found in a browser",
+			urlPath:    "/snippet/raw/missing000",
+			accept:     "text/html,application/xhtml+xml,*/*;q=0.8",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/html; charset=utf-8",
+			wantBody:   "<h2>404 Not Found</h2>",
+		},
+		{
+			name:       "Not acceptable",
+			urlPath:    "/s/pondXy12Ab",
+			accept:     "image/png",
+			wantStatus: http.StatusNotAcceptable,
+			wantType:   "text/html; charset=utf-8",
+			wantBody:   "<h2>406 Not Acceptable</h2>",
+		},
+		{
+			name:       "Raw not found for an image viewer",
+			urlPath:    "/snippet/raw/missing000",
+			accept:     "image/png",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Found",
+		},
+		{
+			name:       "Bad request as JSON",
+			urlPath:    "/?page=0",
+			accept:     "application/json",
+			wantStatus: http.StatusBadRequest,
+			wantType:   "application/json",
+			wantBody:   `{"error":"Bad Request"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAPIRequest(http.MethodGet, tt.urlPath, "", "")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			status, header, body := ts.Do(t, req)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Content-Type"), tt.wantType)
+			assert.StringContains(t, strings.Join(header.Values("Vary"), ", "), "Accept")
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
 func TestApplication_snippetView(t *testing.T) {
 	app := newTestApplication(t)
+	app.idAccess = true
 
 	srv := newTestServer(t, app.routes())
 	defer srv.Close()
@@ -64,6 +277,23 @@ func TestApplication_snippetView(t *testing.T) {
 			urlPath:  "/snippet/view/",
 			wantCode: http.StatusNotFound,
 		},
+		{
+			name:     "Line anchors",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: `<a class="lnlinks" href="#L1">1</a>`,
+		},
+		{
+			name:     "Valid slug",
+			urlPath:  "/s/pondXy12Ab",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/s/pondXy12Ac",
+			wantCode: http.StatusNotFound,
+		},
 	}
 
 	for _, tt := range tests {
@@ -76,116 +306,1569 @@ func TestApplication_snippetView(t *testing.T) {
 	}
 }
 
-func TestUserSignUp(t *testing.T) {
+func TestSnippetViewIDAccess(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	_, _, body := ts.Get(t, "/user/signup")
-	token := extractCSRFToken(t, body)
+	// Numeric IDs are refused by default.
+	status, _, _ := ts.Get(t, "/snippet/view/1")
+	assert.Equal(t, status, http.StatusNotFound)
 
-	const (
-		name     = "Huy"
-		email    = "abc@gmail.com"
-		password = "password"
-		formTag  = `<form action="/user/signup" method="POST" novalidate>`
-	)
+	status, _, body := ts.Get(t, "/s/pondXy12Ab")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond...")
+
+	// Once enabled, they only lead to public snippets.
+	app.idAccess = true
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, _ = ts.Get(t, "/snippet/view/1")
+	assert.Equal(t, status, http.StatusOK)
+	for _, path := range []string{"/snippet/view/3", "/snippet/view/5"} {
+		status, _, _ = ts.Get(t, path)
+		assert.Equal(t, status, http.StatusNotFound)
+	}
+}
+
+func TestSnippetViewVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const privatePath = "/s/forest34Cd"
+
+	status, _, _ := ts.Get(t, privatePath)
+	assert.Equal(t, status, http.StatusNotFound)
+
+	setupAuthencatedSession(t, ts, app, 2)
+	status, _, _ = ts.Get(t, privatePath)
+	assert.Equal(t, status, http.StatusNotFound)
+
+	status, _, _ = ts.Get(t, "/snippet/edit/forest34Cd")
+	assert.Equal(t, status, http.StatusNotFound)
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body := ts.Get(t, privatePath)
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "Over the wintry forest...")
+}
+
+func TestSnippetRawAndDownload(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
 
 	tests := []struct {
-		name         string
-		userName     string
-		userPassword string
-		userEmail    string
-		csrfToken    string
-		wantStatus   int
-		formTag      string
+		name            string
+		urlPath         string
+		wantStatus      int
+		wantType        string
+		wantDisposition string
+		wantBody        string
 	}{
 		{
-			name:         "Valid Submission",
-			userName:     name,
-			userPassword: password,
-			userEmail:    email,
-			csrfToken:    token,
-			wantStatus:   http.StatusSeeOther,
+			name:       "Raw",
+			urlPath:    "/snippet/raw/pondXy12Ab",
+			wantStatus: http.StatusOK,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "An old silent pond...",
 		},
 		{
-			name:         "Missing csqf token",
-			userName:     name,
-			userPassword: password,
-			userEmail:    email,
-			wantStatus:   http.StatusBadRequest,
+			name:            "Download",
+			urlPath:         "/snippet/download/pondXy12Ab",
+			wantStatus:      http.StatusOK,
+			wantType:        "application/octet-stream",
+			wantDisposition: "attachment; filename=an-old-silent-pond.txt",
+			wantBody:        "An old silent pond...",
 		},
 		{
-			name:         "Empty Name",
-			userName:     "",
-			userPassword: password,
-			userEmail:    email,
-			csrfToken:    token,
-			wantStatus:   http.StatusUnprocessableEntity,
-			formTag:      formTag,
+			name:       "Raw by ID",
+			urlPath:    "/snippet/raw/1",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Found",
 		},
 		{
-			name:         "Invalid email",
-			userName:     name,
-			userPassword: password,
-			userEmail:    "bob@email.",
-			csrfToken:    token,
-			wantStatus:   http.StatusUnprocessableEntity,
-			formTag:      formTag,
+			name:       "Raw of a private snippet",
+			urlPath:    "/snippet/raw/forest34Cd",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Found",
 		},
 		{
-			name:         "Email already in use",
-			userName:     name,
-			userPassword: password,
-			userEmail:    "dupe@example.com",
-			csrfToken:    token,
-			wantStatus:   http.StatusUnprocessableEntity,
-			formTag:      formTag,
+			name:       "Raw of a locked snippet",
+			urlPath:    "/snippet/raw/lockedZ9Kq",
+			wantStatus: http.StatusForbidden,
+			wantType:   "text/html; charset=utf-8",
+			wantBody:   "This snippet is protected.",
 		},
 		{
-			name:         "Short password",
-			userName:     name,
-			userPassword: "123",
-			userEmail:    email,
-			csrfToken:    token,
-			wantStatus:   http.StatusUnprocessableEntity,
-			formTag:      formTag,
+			name:       "Download of a non-existent snippet",
+			urlPath:    "/snippet/download/missing123",
+			wantStatus: http.StatusNotFound,
+			wantType:   "text/plain; charset=utf-8",
+			wantBody:   "Not Found",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var form url.Values = map[string][]string{}
-			form.Add("name", tt.userName)
-			form.Add("email", tt.userEmail)
-			form.Add("password", tt.userPassword)
-			form.Add("csrf_token", tt.csrfToken)
-			status, _, body := ts.PostForm(t, "/user/signup", form)
+			status, header, body := ts.Get(t, tt.urlPath)
 
 			assert.Equal(t, status, tt.wantStatus)
-			assert.StringContains(t, body, tt.formTag)
+			assert.Equal(t, header.Get("Content-Type"), tt.wantType)
+			assert.Equal(t, header.Get("Content-Disposition"), tt.wantDisposition)
+			assert.StringContains(t, body, tt.wantBody)
 		})
 	}
 }
 
-func TestSnippetCreate(t *testing.T) {
+func TestDownloadFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet models.Snippet
+		want    string
+	}{
+		{
+			name:    "Title and language",
+			snippet: models.Snippet{Title: "Hello, World!", Language: "go"},
+			want:    "hello-world.go",
+		},
+		{
+			name:    "Unknown language",
+			snippet: models.Snippet{Title: "notes", Language: "cobol"},
+			want:    "notes.txt",
+		},
+		{
+			name:    "No usable characters",
+			snippet: models.Snippet{Title: "¡¿?!", Slug: "pondXy12Ab", Language: "python"},
+			want:    "snippet-pondXy12Ab.py",
+		},
+		{
+			name:    "Long title",
+			snippet: models.Snippet{Title: strings.Repeat("ab ", 40), Language: "plaintext"},
+			want:    strings.Repeat("ab-", 21) + "a.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, downloadFileName(&tt.snippet), tt.want)
+		})
+	}
+}
+
+func TestTagView(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	t.Run("Unauthenticated", func(t *testing.T) {
-		status, header, _ := ts.Get(t, "/snippet/create")
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Tag with snippets",
+			urlPath:    "/tag/haiku",
+			wantStatus: http.StatusOK,
+			wantBody:   "<a href='/s/pondXy12Ab'>An old silent pond</a>",
+		},
+		{
+			name:       "Tag without snippets",
+			urlPath:    "/tag/rust",
+			wantStatus: http.StatusOK,
+			wantBody:   "There are no snippets tagged rust.",
+		},
+		{
+			name:       "Invalid tag",
+			urlPath:    "/tag/Haiku",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid page",
+			urlPath:    "/tag/haiku?page=0",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
 
-		assert.Equal(t, status, http.StatusSeeOther)
-		assert.Equal(t, header.Get("Location"), "/user/login")
-	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, body := ts.Get(t, tt.urlPath)
 
-	t.Run("Authenticated", func(t *testing.T) {
-		setupAuthencatedSession(t, ts, app, 1)
-		status, _, body := ts.Get(t, "/snippet/create")
-		assert.Equal(t, status, http.StatusOK)
-		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
-	})
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, _, body := ts.Get(t, "/tags")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<a class='tag size-5' href='/tag/haiku'>haiku <span>3</span></a>")
+	assert.StringContains(t, body, "<a class='tag size-1' href='/tag/nature'>nature <span>1</span></a>")
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "History",
+			urlPath:  "/snippet/view/pondXy12Ab/history",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/pondXy12Ab/diff?from=1&to=2'>diff</a>",
+		},
+		{
+			name:     "Private history",
+			urlPath:  "/snippet/view/forest34Cd/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Revision",
+			urlPath:  "/snippet/view/pondXy12Ab/rev/1",
+			wantCode: http.StatusOK,
+			wantBody: "An old pond...",
+		},
+		{
+			name:     "Revision of several files",
+			urlPath:  "/snippet/view/pondXy12Ab/rev/1",
+			wantCode: http.StatusOK,
+			wantBody: "<strong>notes.txt</strong>",
+		},
+		{
+			name:     "Non-existent revision",
+			urlPath:  "/snippet/view/pondXy12Ab/rev/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Default diff",
+			urlPath:  "/snippet/view/pondXy12Ab/diff",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='add'>&#43;An old silent pond...</span>",
+		},
+		{
+			name:     "Diff of a removed file",
+			urlPath:  "/snippet/view/pondXy12Ab/diff",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='file'>--- notes.txt (revision 1)</span><span class='file'>&#43;&#43;&#43; notes.txt (revision 2)</span><span class='hunk'>@@ -1 &#43;0,0 @@</span><span class='del'>-By Matsuo Basho</span>",
+		},
+		{
+			name:     "Identical revisions",
+			urlPath:  "/snippet/view/pondXy12Ab/diff?from=2&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "The content of these revisions is identical.",
+		},
+		{
+			name:     "Out of range diff",
+			urlPath:  "/snippet/view/pondXy12Ab/diff?from=0&to=2",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.Get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/snippet/search",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/snippet/search' method='GET' class='search'>",
+		},
+		{
+			name:     "Matched query",
+			urlPath:  "/snippet/search?q=silent",
+			wantCode: http.StatusOK,
+			wantBody: "An old <mark>silent</mark> pond...",
+		},
+		{
+			name:     "Unmatched query",
+			urlPath:  "/snippet/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets matched your search.",
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/snippet/search?q=silent&page=-1",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.Get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestUserSignUp(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.Get(t, "/user/signup")
+	token := extractCSRFToken(t, body)
+
+	const (
+		name     = "Huy"
+		email    = "abc@gmail.com"
+		password = "password"
+		formTag  = `<form action="/user/signup" method="POST" novalidate>`
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userPassword string
+		userEmail    string
+		csrfToken    string
+		wantStatus   int
+		formTag      string
+	}{
+		{
+			name:         "Valid Submission",
+			userName:     name,
+			userPassword: password,
+			userEmail:    email,
+			csrfToken:    token,
+			wantStatus:   http.StatusSeeOther,
+		},
+		{
+			name:         "Missing csqf token",
+			userName:     name,
+			userPassword: password,
+			userEmail:    email,
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:         "Empty Name",
+			userName:     "",
+			userPassword: password,
+			userEmail:    email,
+			csrfToken:    token,
+			wantStatus:   http.StatusUnprocessableEntity,
+			formTag:      formTag,
+		},
+		{
+			name:         "Invalid email",
+			userName:     name,
+			userPassword: password,
+			userEmail:    "bob@email.",
+			csrfToken:    token,
+			wantStatus:   http.StatusUnprocessableEntity,
+			formTag:      formTag,
+		},
+		{
+			name:         "Email already in use",
+			userName:     name,
+			userPassword: password,
+			userEmail:    "dupe@example.com",
+			csrfToken:    token,
+			wantStatus:   http.StatusUnprocessableEntity,
+			formTag:      formTag,
+		},
+		{
+			name:         "Short password",
+			userName:     name,
+			userPassword: "123",
+			userEmail:    email,
+			csrfToken:    token,
+			wantStatus:   http.StatusUnprocessableEntity,
+			formTag:      formTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values = map[string][]string{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+			status, _, body := ts.PostForm(t, "/user/signup", form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.formTag)
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		status, header, _ := ts.Get(t, "/snippet/create")
+
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		setupAuthencatedSession(t, ts, app, 1)
+		status, _, body := ts.Get(t, "/snippet/create")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
+	})
+
+	_, _, body := ts.Get(t, "/snippet/create")
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		expires    string
+		expiresAt  string
+		password   string
+		tags       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Duration",
+			expires:    "10m",
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Never",
+			expires:    "never",
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Burn after reading",
+			expires:    "burn",
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Custom date",
+			expires:    "custom",
+			expiresAt:  time.Now().UTC().Add(48 * time.Hour).Format("2006-01-02T15:04"),
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Custom date in the past",
+			expires:    "custom",
+			expiresAt:  "2020-01-01T10:00",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be in the future",
+		},
+		{
+			name:       "Malformed custom date",
+			expires:    "custom",
+			expiresAt:  "tomorrow",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be a date and time",
+		},
+		{
+			name:       "Keep without a snippet",
+			expires:    "keep",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be one of the listed options",
+		},
+		{
+			name:       "Unknown option",
+			expires:    "7",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be one of the listed options",
+		},
+		{
+			name:       "Password",
+			expires:    "7d",
+			password:   "pa$$word",
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Tags",
+			expires:    "7d",
+			tags:       "Go, http, , go, c++",
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Too many tags",
+			expires:    "7d",
+			tags:       "a, b, c, d, e, f",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t have more than 5 tags",
+		},
+		{
+			name:       "Tag too long",
+			expires:    "7d",
+			tags:       strings.Repeat("a", 33),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Each tag can&#39;t be more than 32 characters long",
+		},
+		{
+			name:       "Tag with invalid characters",
+			expires:    "7d",
+			tags:       "go, hello world",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Each tag must only contain letters, digits, dashes, dots and pluses",
+		},
+		{
+			name:       "Short password",
+			expires:    "7d",
+			password:   "pa$$",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be at least 8 characters long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A title")
+			form.Add("files[0].content", "Some content")
+			form.Add("files[0].language", "plaintext")
+			form.Add("visibility", "public")
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("password", tt.password)
+			form.Add("tags", tt.tags)
+			form.Add("csrf_token", token)
+			status, _, body := ts.PostForm(t, "/snippet/create", form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetCreateFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	_, _, body := ts.Get(t, "/snippet/create")
+	token := extractCSRFToken(t, body)
+
+	type file struct {
+		name, language, content string
+	}
+
+	tests := []struct {
+		name          string
+		files         []file
+		addFile       bool
+		removeFile    string
+		wantStatus    int
+		wantBody      string
+		wantNotInBody string
+	}{
+		{
+			name:       "Add a file",
+			files:      []file{{"main.go", "go", "package main"}},
+			addFile:    true,
+			wantStatus: http.StatusOK,
+			wantBody:   "<textarea name='files[1].content'></textarea>",
+		},
+		{
+			name:          "Remove a file",
+			files:         []file{{"main.go", "go", "package main"}, {"go.mod", "plaintext", "module hello"}},
+			removeFile:    "0",
+			wantStatus:    http.StatusOK,
+			wantBody:      "<textarea name='files[0].content'>module hello</textarea>",
+			wantNotInBody: "package main",
+		},
+		{
+			name:       "Several files",
+			files:      []file{{"main.go", "go", "package main"}, {"", "plaintext", "module hello"}},
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Same name twice",
+			files:      []file{{"main.go", "go", "package main"}, {"main.go", "go", "package main"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Another file already has this name",
+		},
+		{
+			name:       "Same name as a default one",
+			files:      []file{{"", "go", "package main"}, {"file1.go", "go", "package main"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Another file already has this name",
+		},
+		{
+			name:       "Invalid name",
+			files:      []file{{"../main.go", "go", "package main"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must only contain letters, digits, spaces, dots, dashes, underscores and pluses",
+		},
+		{
+			name:       "Empty second file",
+			files:      []file{{"main.go", "go", "package main"}, {"go.mod", "plaintext", ""}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A title")
+			form.Add("expires", "7d")
+			form.Add("visibility", "public")
+			for i, f := range tt.files {
+				form.Add(fmt.Sprintf("files[%d].name", i), f.name)
+				form.Add(fmt.Sprintf("files[%d].language", i), f.language)
+				form.Add(fmt.Sprintf("files[%d].content", i), f.content)
+			}
+			if tt.addFile {
+				form.Add("add_file", "true")
+			}
+			form.Add("remove_file", tt.removeFile)
+			form.Add("csrf_token", token)
+			status, _, body := ts.PostForm(t, "/snippet/create", form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.wantBody)
+			if tt.wantNotInBody != "" {
+				assert.StringNotContains(t, body, tt.wantNotInBody)
+			}
+		})
+	}
+}
+
+func TestSnippetViewFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, _, body := ts.Get(t, "/s/bundleQ7Rt")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<strong>main.go</strong>")
+	assert.StringContains(t, body, "<a href='/snippet/raw/bundleQ7Rt?file=go.mod'>Raw</a>")
+	assert.StringContains(t, body, `id="L1"`)
+	assert.StringContains(t, body, `id="F2L1"`)
+
+	status, _, body = ts.Get(t, "/snippet/raw/bundleQ7Rt?file=go.mod")
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, body, "module hello")
+
+	status, _, _ = ts.Get(t, "/snippet/raw/bundleQ7Rt?file=missing.go")
+	assert.Equal(t, status, http.StatusNotFound)
+}
+
+func TestSnippetViewMarkdown(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, _, body := ts.Get(t, "/s/notesM3Dn")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, `<div class='markdown'><h1 id="team-notes"><a href="#team-notes" class="anchor">#</a>Team notes</h1>`)
+	assert.StringNotContains(t, body, "<script>alert(1)</script>")
+
+	status, _, body = ts.Get(t, "/snippet/raw/notesM3Dn")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<script>alert(1)</script>")
+}
+
+func TestSnippetDownloadZip(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, header, body := ts.Get(t, "/snippet/download/bundleQ7Rt")
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/zip")
+	assert.Equal(t, header.Get("Content-Disposition"), "attachment; filename=hello-server.zip")
+
+	zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"main.go": "package main", "go.mod": "module hello"}
+	assert.Equal(t, len(zr.File), len(want))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, string(content), want[f.Name])
+	}
+}
+
+func TestSnippetViewBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, _, body := ts.Get(t, "/s/burnMe78Gh")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "This snippet has been deleted now that you've read it.")
+	assert.StringNotContains(t, body, "/snippet/view/burnMe78Gh/history")
+
+	status, _, _ = ts.Get(t, "/snippet/view/burnMe78Gh/history")
+	assert.Equal(t, status, http.StatusNotFound)
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body = ts.Get(t, "/s/burnMe78Gh")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "This snippet will be deleted the first time someone else reads it.")
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, _, body := ts.Get(t, "/s/lockedZ9Kq")
+	assert.Equal(t, status, http.StatusForbidden)
+	assert.StringContains(t, body, "<form action='/snippet/unlock/lockedZ9Kq' method='POST' novalidate>")
+	assert.StringNotContains(t, body, "hunter2")
+
+	status, header, _ := ts.Get(t, "/snippet/view/lockedZ9Kq/history")
+	assert.Equal(t, status, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/s/lockedZ9Kq")
+
+	token := extractCSRFToken(t, body)
+	unlock := func(password string) (int, string) {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", token)
+		status, _, body := ts.PostForm(t, "/snippet/unlock/lockedZ9Kq", form)
+		return status, body
+	}
+
+	status, body = unlock("")
+	assert.Equal(t, status, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field can&#39;t be blank")
+
+	status, body = unlock("wrong password")
+	assert.Equal(t, status, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "The password is incorrect")
+
+	status, _ = unlock("pa$$word")
+	assert.Equal(t, status, http.StatusSeeOther)
+
+	status, _, body = ts.Get(t, "/s/lockedZ9Kq")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "password = hunter2")
+
+	status, _, _ = ts.Get(t, "/snippet/view/lockedZ9Kq/history")
+	assert.Equal(t, status, http.StatusOK)
+
+	status, _, _ = ts.Get(t, "/s/pondXy12Ab")
+	assert.Equal(t, status, http.StatusOK)
+}
+
+func TestSnippetUnlockAuthor(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body := ts.Get(t, "/s/lockedZ9Kq")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "password = hunter2")
+	assert.StringContains(t, body, "password protected")
+}
+
+func TestSnippetUnlockRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.Get(t, "/s/lockedZ9Kq")
+	token := extractCSRFToken(t, body)
+	unlock := func(password string) (int, string) {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", token)
+		status, _, body := ts.PostForm(t, "/snippet/unlock/lockedZ9Kq", form)
+		return status, body
+	}
+
+	for i := 0; i < unlockMaxAttempts; i++ {
+		status, _ := unlock("wrong password")
+		assert.Equal(t, status, http.StatusUnprocessableEntity)
+	}
+
+	status, body := unlock("pa$$word")
+	assert.Equal(t, status, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many failed attempts. Please try again later")
+}
+
+func TestSnippetViewComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, _, body := ts.Get(t, "/s/pondXy12Ab")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<div class='comment depth-0' id='comment-1'>")
+	assert.StringContains(t, body, "<div class='comment depth-1' id='comment-2'>")
+	assert.StringContains(t, body, "Lovely, but <a href='#L1'>L1</a> could be &lt;b&gt;shorter&lt;/b&gt;.")
+	assert.StringNotContains(t, body, "<form class='comment'")
+
+	setupAuthencatedSession(t, ts, app, 2)
+	_, _, body = ts.Get(t, "/s/pondXy12Ab")
+	assert.StringContains(t, body, "<form class='comment' method='POST' action='/snippet/comment/pondXy12Ab' novalidate>")
+	assert.StringContains(t, body, "<form method='POST' action='/comment/delete/1'>")
+	assert.StringNotContains(t, body, "<form method='POST' action='/comment/delete/2'>")
+}
+
+func TestSnippetViewCountsViews(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.Get(t, "/s/pondXy12Ab")
+	setupAuthencatedSession(t, ts, app, 2)
+	ts.Get(t, "/s/pondXy12Ab")
+	setupAuthencatedSession(t, ts, app, 1)
+	ts.Get(t, "/s/pondXy12Ab")
+
+	b := app.viewCounter.Take()
+	assert.Equal(t, len(b.Views), 1)
+	assert.Equal(t, b.Views[0].SnippetID, 1)
+	assert.Equal(t, b.Views[0].Views, 2)
+	assert.Equal(t, len(b.Visitors), 1)
+}
+
+func TestSnippetStats(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		status, header, _ := ts.Get(t, "/snippet/stats/pondXy12Ab")
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	t.Run("Someone else", func(t *testing.T) {
+		setupAuthencatedSession(t, ts, app, 2)
+		status, _, _ := ts.Get(t, "/snippet/stats/pondXy12Ab")
+		assert.Equal(t, status, http.StatusForbidden)
+	})
+
+	t.Run("Author", func(t *testing.T) {
+		setupAuthencatedSession(t, ts, app, 1)
+		status, _, body := ts.Get(t, "/snippet/stats/pondXy12Ab")
+		assert.Equal(t, status, http.StatusOK)
+		assert.StringContains(t, body, "12 views in total.")
+		assert.StringContains(t, body, "<td>11 May 2023</td>")
+		assert.StringContains(t, body, "<td>news.ycombinator.com</td>")
+	})
+}
+
+func TestSnippetStar(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.Get(t, "/user/login")
+	token := extractCSRFToken(t, body)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", token)
+		status, header, _ := ts.PostForm(t, "/snippet/star/pondXy12Ab", form)
+
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	tests := []struct {
+		name       string
+		userID     int
+		urlPath    string
+		wantStatus int
+		wantHeader string
+		wantFlash  string
+	}{
+		{
+			name:       "Star",
+			userID:     2,
+			urlPath:    "/snippet/star/pondXy12Ab",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab",
+			wantFlash:  "Snippet has been starred!",
+		},
+		{
+			name:       "Unstar",
+			userID:     1,
+			urlPath:    "/snippet/star/pondXy12Ab",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab",
+			wantFlash:  "Snippet has been unstarred!",
+		},
+		{
+			name:       "Private snippet of someone else",
+			userID:     2,
+			urlPath:    "/snippet/star/forest34Cd",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Non-existent snippet",
+			userID:     2,
+			urlPath:    "/snippet/star/missing999",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupAuthencatedSession(t, ts, app, tt.userID)
+			form := url.Values{}
+			form.Add("csrf_token", token)
+			status, header, _ := ts.PostForm(t, tt.urlPath, form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Location"), tt.wantHeader)
+			if tt.wantFlash != "" {
+				_, _, body := ts.Get(t, tt.wantHeader)
+				assert.StringContains(t, body, tt.wantFlash)
+			}
+		})
+	}
+}
+
+func TestSnippetViewStars(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.Get(t, "/s/pondXy12Ab")
+	assert.StringContains(t, body, "2 stars")
+	assert.StringNotContains(t, body, "action='/snippet/star/pondXy12Ab'")
+
+	setupAuthencatedSession(t, ts, app, 1)
+	_, _, body = ts.Get(t, "/s/pondXy12Ab")
+	assert.StringContains(t, body, "<button>Unstar</button>")
+
+	setupAuthencatedSession(t, ts, app, 2)
+	_, _, body = ts.Get(t, "/s/pondXy12Ab")
+	assert.StringContains(t, body, "<button>Star</button>")
+}
+
+func TestCommentCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.Get(t, "/user/login")
+	token := extractCSRFToken(t, body)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("body", "A comment")
+		form.Add("csrf_token", token)
+		status, header, _ := ts.PostForm(t, "/snippet/comment/pondXy12Ab", form)
+
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	setupAuthencatedSession(t, ts, app, 2)
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		body       string
+		parentID   string
+		wantStatus int
+		wantHeader string
+		wantBody   string
+	}{
+		{
+			name:       "Valid comment",
+			urlPath:    "/snippet/comment/pondXy12Ab",
+			body:       "See L1",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab#comment-3",
+		},
+		{
+			name:       "Valid reply",
+			urlPath:    "/snippet/comment/pondXy12Ab",
+			body:       "Agreed",
+			parentID:   "1",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab#comment-3",
+		},
+		{
+			name:       "Reply to a missing comment",
+			urlPath:    "/snippet/comment/pondXy12Ab",
+			body:       "Agreed",
+			parentID:   "99",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Empty body",
+			urlPath:    "/snippet/comment/pondXy12Ab",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t be blank",
+		},
+		{
+			name:       "Too long body",
+			urlPath:    "/snippet/comment/pondXy12Ab",
+			body:       strings.Repeat("a", maxCommentLength+1),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t be more than 2000 characters long",
+		},
+		{
+			name:       "Private snippet of someone else",
+			urlPath:    "/snippet/comment/forest34Cd",
+			body:       "A comment",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Burn-after-reading snippet",
+			urlPath:    "/snippet/comment/burnMe78Gh",
+			body:       "A comment",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("parent_id", tt.parentID)
+			form.Add("csrf_token", token)
+			status, header, body := ts.PostForm(t, tt.urlPath, form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Location"), tt.wantHeader)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestCommentDelete(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     int
+		urlPath    string
+		wantStatus int
+		wantHeader string
+	}{
+		{
+			name:       "Comment author",
+			userID:     2,
+			urlPath:    "/comment/delete/1",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab#comments",
+		},
+		{
+			name:       "Snippet author",
+			userID:     1,
+			urlPath:    "/comment/delete/1",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab#comments",
+		},
+		{
+			name:       "Someone else",
+			userID:     2,
+			urlPath:    "/comment/delete/2",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Non-existent comment",
+			userID:     1,
+			urlPath:    "/comment/delete/99",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			setupAuthencatedSession(t, ts, app, tt.userID)
+			_, _, body := ts.Get(t, "/s/pondXy12Ab")
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+			status, header, _ := ts.PostForm(t, tt.urlPath, form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Location"), tt.wantHeader)
+		})
+	}
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		status, header, _ := ts.Get(t, "/snippet/edit/pondXy12Ab")
+
+		assert.Equal(t, status, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	t.Run("Not the author", func(t *testing.T) {
+		setupAuthencatedSession(t, ts, app, 2)
+		status, _, _ := ts.Get(t, "/snippet/edit/pondXy12Ab")
+
+		assert.Equal(t, status, http.StatusForbidden)
+	})
+
+	setupAuthencatedSession(t, ts, app, 1)
+	_, _, body := ts.Get(t, "/snippet/edit/pondXy12Ab")
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		title      string
+		wantStatus int
+		wantHeader string
+	}{
+		{
+			name:       "Valid submission",
+			urlPath:    "/snippet/edit/pondXy12Ab",
+			title:      "A new title",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/s/pondXy12Ab",
+		},
+		{
+			name:       "Empty title",
+			urlPath:    "/snippet/edit/pondXy12Ab",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Non-existent slug",
+			urlPath:    "/snippet/edit/missing000",
+			title:      "A new title",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("files[0].content", "Some content")
+			form.Add("files[0].language", "go")
+			form.Add("expires", "7d")
+			form.Add("visibility", "unlisted")
+			form.Add("csrf_token", token)
+			status, header, _ := ts.PostForm(t, tt.urlPath, form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Location"), tt.wantHeader)
+		})
+	}
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 2)
+	_, _, body := ts.Get(t, "/s/pondXy12Ab")
+	assert.StringContains(t, body, "public, 1 fork")
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		wantStatus int
+		wantHeader string
+	}{
+		{
+			name:       "Valid snippet",
+			urlPath:    "/snippet/fork/pondXy12Ab",
+			wantStatus: http.StatusSeeOther,
+			wantHeader: "/snippet/edit/newSnip56E",
+		},
+		{
+			name:       "Private snippet of another user",
+			urlPath:    "/snippet/fork/forest34Cd",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Expired or non-existent snippet",
+			urlPath:    "/snippet/fork/missing000",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", token)
+			status, header, _ := ts.PostForm(t, tt.urlPath, form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.Equal(t, header.Get("Location"), tt.wantHeader)
+		})
+	}
+}
+
+func TestSnippetForkProtected(t *testing.T) {
+	app := newTestApplication(t)
+	snippets := &mock.StubSnippets{}
+	app.snippet = snippets
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 2)
+	_, _, body := ts.Get(t, "/s/lockedZ9Kq")
+	form := url.Values{}
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	status, _, _ := ts.PostForm(t, "/snippet/unlock/lockedZ9Kq", form)
+	assert.Equal(t, status, http.StatusSeeOther)
+
+	form.Del("password")
+	status, _, _ = ts.PostForm(t, "/snippet/fork/lockedZ9Kq", form)
+	assert.Equal(t, status, http.StatusSeeOther)
+
+	// The fork doesn't get the password, so it must not be listed.
+	fork := snippets.Inserted
+	assert.Equal(t, fork.Visibility, models.VisibilityPrivate)
+	assert.Equal(t, fork.ForkedFrom, 5)
+	wantExpires := time.Now().Add(expiryDurations[defaultExpiry])
+	assert.Equal(t, fork.Expires.Sub(wantExpires) < time.Minute, true)
+	assert.Equal(t, wantExpires.Sub(fork.Expires) < time.Minute, true)
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 2)
+	_, _, body := ts.Get(t, "/snippet/create")
+	token := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("csrf_token", token)
+
+	status, _, _ := ts.PostForm(t, "/snippet/delete/pondXy12Ab", form)
+	assert.Equal(t, status, http.StatusForbidden)
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, header, _ := ts.PostForm(t, "/snippet/delete/pondXy12Ab", form)
+	assert.Equal(t, status, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/view")
+}
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body := ts.Get(t, "/account/view")
+
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "My Snippets")
+	assert.StringContains(t, body, "<a href='/s/pondXy12Ab'>An old silent pond</a>")
+}
+
+func TestAccountStarred(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body := ts.Get(t, "/account/starred")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/s/pondXy12Ab'>An old silent pond</a>")
+	assert.StringContains(t, body, "&#9733; 2")
+
+	setupAuthencatedSession(t, ts, app, 2)
+	status, _, body = ts.Get(t, "/account/starred")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "You haven't starred any snippets yet.")
+}
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body := ts.Get(t, "/account/tokens")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<td>deploy script</td>")
+	assert.StringContains(t, body, "<td>snippets:read, snippets:write</td>")
+	assert.StringNotContains(t, body, "editor plugin")
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		tokenName  string
+		scopes     []string
+		expires    string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Valid",
+			tokenName:  "CI",
+			scopes:     []string{"snippets:read"},
+			expires:    "30d",
+			wantStatus: http.StatusCreated,
+			wantBody:   "<code class='token'>sbp_newtoken</code>",
+		},
+		{
+			name:       "Never expires",
+			tokenName:  "CI",
+			scopes:     []string{"snippets:read", "snippets:write"},
+			expires:    "never",
+			wantStatus: http.StatusCreated,
+			wantBody:   "sbp_newtoken",
+		},
+		{
+			name:       "Blank name",
+			scopes:     []string{"snippets:read"},
+			expires:    "30d",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t be blank",
+		},
+		{
+			name:       "No scopes",
+			tokenName:  "CI",
+			expires:    "30d",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Pick at least one scope",
+		},
+		{
+			name:       "Unknown scope",
+			tokenName:  "CI",
+			scopes:     []string{"users:write"},
+			expires:    "30d",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must only hold the listed scopes",
+		},
+		{
+			name:       "Invalid expiry",
+			tokenName:  "CI",
+			scopes:     []string{"snippets:read"},
+			expires:    "10m",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be one of the listed options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.tokenName)
+			for _, scope := range tt.scopes {
+				form.Add("scopes", scope)
+			}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", token)
+			status, header, body := ts.PostForm(t, "/account/tokens", form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			assert.StringContains(t, body, tt.wantBody)
+			if tt.wantStatus == http.StatusCreated {
+				assert.Equal(t, header.Get("Cache-Control"), "no-store")
+			}
+		})
+	}
+}
+
+func TestAccountTokenRevoke(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	_, _, body := ts.Get(t, "/account/tokens")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	status, header, _ := ts.PostForm(t, "/account/tokens/revoke/1", form)
+	assert.Equal(t, status, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/tokens")
+
+	status, _, _ = ts.PostForm(t, "/account/tokens/revoke/2", form)
+	assert.Equal(t, status, http.StatusNotFound)
+}
+
+func TestAccountWebhooks(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	status, _, body := ts.Get(t, "/account/webhooks")
+	assert.Equal(t, status, http.StatusOK)
+	assert.StringContains(t, body, "<td>https://ci.example.com/hooks/snippetbox</td>")
+	assert.StringContains(t, body, "<td>snippet.created, snippet.expired</td>")
+	assert.StringContains(t, body, "<td>204</td>")
+	assert.StringContains(t, body, "<td>connection refused</td>")
+	assert.StringNotContains(t, body, "a very secret secret")
+	token := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		webhookURL string
+		secret     string
+		events     []string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Valid",
+			webhookURL: "https://example.com/hooks",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created", "snippet.expired"},
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "Blank URL",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t be blank",
+		},
+		{
+			name:       "Relative URL",
+			webhookURL: "/hooks",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be an http or https URL",
+		},
+		{
+			name:       "Other scheme",
+			webhookURL: "ftp://example.com/hooks",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be an http or https URL",
+		},
+		{
+			name:       "Loopback address",
+			webhookURL: "http://127.0.0.1:8080/hooks",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t point to an internal address",
+		},
+		{
+			name:       "Metadata address",
+			webhookURL: "http://169.254.169.254/latest/meta-data",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t point to an internal address",
+		},
+		{
+			name:       "Localhost",
+			webhookURL: "http://localhost/hooks",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field can&#39;t point to an internal address",
+		},
+		{
+			name:       "Short secret",
+			webhookURL: "https://example.com/hooks",
+			secret:     "secret",
+			events:     []string{"snippet.created"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must be at least 16 characters long",
+		},
+		{
+			name:       "No events",
+			webhookURL: "https://example.com/hooks",
+			secret:     "sixteen chars ok",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Pick at least one event",
+		},
+		{
+			name:       "Unknown event",
+			webhookURL: "https://example.com/hooks",
+			secret:     "sixteen chars ok",
+			events:     []string{"snippet.deleted"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "This field must only hold the listed events",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("url", tt.webhookURL)
+			form.Add("secret", tt.secret)
+			for _, event := range tt.events {
+				form.Add("events", event)
+			}
+			form.Add("csrf_token", token)
+			status, header, body := ts.PostForm(t, "/account/webhooks", form)
+
+			assert.Equal(t, status, tt.wantStatus)
+			if tt.wantStatus == http.StatusSeeOther {
+				assert.Equal(t, header.Get("Location"), "/account/webhooks")
+				return
+			}
+			assert.StringContains(t, body, tt.wantBody)
+			assert.StringNotContains(t, body, "value='"+tt.secret+"'")
+		})
+	}
+}
+
+func TestAccountWebhookDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	setupAuthencatedSession(t, ts, app, 1)
+	_, _, body := ts.Get(t, "/account/webhooks")
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	status, header, _ := ts.PostForm(t, "/account/webhooks/delete/1", form)
+	assert.Equal(t, status, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/webhooks")
+
+	status, _, _ = ts.PostForm(t, "/account/webhooks/delete/2", form)
+	assert.Equal(t, status, http.StatusNotFound)
 }
 
 func setupAuthencatedSession(t *testing.T, ts *testServer, app *Application, userID int) {
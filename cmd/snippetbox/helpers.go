@@ -4,45 +4,190 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/huytran2000-hcmus/snippetbox/internal/validator"
 	"github.com/justinas/nosurf"
 )
 
-func (app *Application) serverError(w http.ResponseWriter, err error) {
+// Media types of the formats negotiated routes and error responses come in.
+const (
+	mediaHTML = "text/html"
+	mediaJSON = "application/json"
+	mediaText = "text/plain"
+)
+
+// negotiatedMedia are the negotiated media types, most preferred first.
+var negotiatedMedia = []string{mediaHTML, mediaJSON, mediaText}
+
+// plainMedia are the media types errors of routes serving raw content are
+// negotiated among, most preferred first.
+var plainMedia = []string{mediaText, mediaJSON, mediaHTML}
+
+// negotiate returns the media type to respond to r with, picked among
+// negotiatedMedia by the Accept header of r. Ties go to the most preferred
+// one, and HTML is used when there's no Accept header. It returns an empty
+// string when the Accept header accepts none of them.
+func negotiate(r *http.Request) string {
+	return negotiateAmong(r, negotiatedMedia)
+}
+
+// negotiateAmong is like negotiate but picks among the given media types,
+// the first of which is used when there's no Accept header.
+func negotiateAmong(r *http.Request, preferred []string) string {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if accept == "" {
+		return preferred[0]
+	}
+
+	best, bestQuality := "", 0.0
+	for _, media := range preferred {
+		q := acceptQuality(accept, media)
+		if q > bestQuality {
+			best, bestQuality = media, q
+		}
+	}
+
+	return best
+}
+
+// acceptQuality returns the quality an Accept header gives media, taken from
+// the most specific media range matching it, or 0 when none does.
+func acceptQuality(accept string, media string) float64 {
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch {
+		case mediaRange == media:
+			s = 2
+		case mediaRange == media[:strings.Index(media, "/")]+"/*":
+			s = 1
+		case mediaRange == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				q = 0
+			}
+		}
+		quality, specificity = q, s
+	}
+
+	return quality
+}
+
+// varyAccept tells caches that the response depends on the Accept header.
+func varyAccept(w http.ResponseWriter) {
+	for _, v := range w.Header().Values("Vary") {
+		if v == "Accept" {
+			return
+		}
+	}
+	w.Header().Add("Vary", "Accept")
+}
+
+func (app *Application) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	stackStrace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errLog.Output(2, stackStrace)
 
 	if app.debug {
-		http.Error(w, stackStrace, http.StatusInternalServerError)
+		app.errorResponse(w, r, http.StatusInternalServerError, stackStrace)
 		return
 	}
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.errorResponse(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 }
 
-func (app *Application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+func (app *Application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.errorResponse(w, r, status, http.StatusText(status))
 }
 
-func (app *Application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+func (app *Application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// errorResponse writes an error in the format negotiated for r: the error
+// page, a JSON object like the API errors, or plain text. Routes serving raw
+// content default to plain text, which suits tools like curl.
+func (app *Application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
+	varyAccept(w)
+	preferred := negotiatedMedia
+	if plain, _ := r.Context().Value(plainErrorsCtxKey).(bool); plain {
+		preferred = plainMedia
+	}
+	// An error is still sent when the client accepts none of the formats,
+	// in the default one, rather than leaving it without a reason.
+	media := negotiateAmong(r, preferred)
+	if media == "" {
+		media = preferred[0]
+	}
+
+	switch media {
+	case mediaJSON:
+		app.writeJSON(w, status, apiError{Error: message})
+	case mediaText:
+		http.Error(w, message, status)
+	default:
+		app.renderError(w, r, status, message)
+	}
 }
 
-func (app *Application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+// renderError renders the error page. It falls back to plain text rather than
+// reporting a server error, which would render the page again.
+func (app *Application) renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	data := &templateData{
+		Error:           &errorPage{Status: status, Title: http.StatusText(status), Message: message},
+		CurrentYear:     time.Now().Year(),
+		IsAuthenticated: app.isAuthenticated(r),
+		AuthenticatedID: app.authenticatedUserID(r),
+		CSRFToken:       nosurf.Token(r),
+	}
+
+	var buf bytes.Buffer
+	t, ok := app.templateCache["error"]
+	if !ok || t.ExecuteTemplate(&buf, "base", data) != nil {
+		http.Error(w, message, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+func (app *Application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
 	t, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %q does not exists", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	var buf bytes.Buffer
 	err := t.ExecuteTemplate(&buf, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -50,11 +195,45 @@ func (app *Application) render(w http.ResponseWriter, status int, page string, d
 	buf.WriteTo(w)
 }
 
+// representation is a response of a route serving HTML, JSON and plain text
+// from the same URL. Data builds the template data of Page, and is only
+// called when HTML is negotiated.
+type representation struct {
+	Page string
+	Data func() (*templateData, error)
+	JSON any
+	Text string
+}
+
+// respond writes rep in the format negotiated for r, or a 406 Not Acceptable
+// error when r accepts none of them.
+func (app *Application) respond(w http.ResponseWriter, r *http.Request, status int, rep *representation) {
+	varyAccept(w)
+	switch negotiate(r) {
+	case "":
+		app.clientError(w, r, http.StatusNotAcceptable)
+	case mediaJSON:
+		app.writeJSON(w, status, rep.JSON)
+	case mediaText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		io.WriteString(w, rep.Text)
+	default:
+		data, err := rep.Data()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.render(w, r, status, rep.Page, data)
+	}
+}
+
 func (app *Application) newDefaultTemplateData(r *http.Request) *templateData {
 	return &templateData{
 		CurrentYear:     time.Now().Year(),
 		FlashMessage:    app.sessionManager.PopString(r.Context(), flashMessKey),
 		IsAuthenticated: app.isAuthenticated(r),
+		AuthenticatedID: app.authenticatedUserID(r),
 		CSRFToken:       nosurf.Token(r),
 	}
 }
@@ -74,6 +253,36 @@ func (app *Application) decodePostForm(r *http.Request, dst interface{}) error {
 	return err
 }
 
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// readPagination reads the page and page_size query parameters of r. Any
+// invalid parameter is reported as a field error on v.
+func readPagination(r *http.Request, v *validator.Validator) *pagination {
+	query := r.URL.Query()
+	p := &pagination{
+		Page:     1,
+		PageSize: defaultPageSize,
+		url:      *r.URL,
+	}
+
+	if query.Has("page") {
+		p.Page = v.CheckField("page", query.Get("page")).
+			IntBetween("This parameter must be a positive number", 1, math.MaxInt32).
+			ToInt("This parameter must be a positive number")
+	}
+
+	if query.Has("page_size") {
+		p.PageSize = v.CheckField("page_size", query.Get("page_size")).
+			IntBetween(fmt.Sprintf("This parameter must be a number between 1 and %d", maxPageSize), 1, maxPageSize).
+			ToInt(fmt.Sprintf("This parameter must be a number between 1 and %d", maxPageSize))
+	}
+
+	return p
+}
+
 func (app *Application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(isAuthenticatedCtxKey).(bool)
 	if !ok {
@@ -82,3 +291,22 @@ func (app *Application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+func (app *Application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(userIDCtxKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+// clientIP returns the IP address of the client, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
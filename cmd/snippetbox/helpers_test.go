@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "No Accept header", want: mediaHTML},
+		{name: "Anything", accept: "*/*", want: mediaHTML},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: mediaHTML},
+		{name: "JSON", accept: "application/json", want: mediaJSON},
+		{name: "Plain text", accept: "text/plain", want: mediaText},
+		{name: "Plain text with parameters", accept: "text/plain; charset=utf-8", want: mediaText},
+		{name: "Quality", accept: "text/html;q=0.5, application/json;q=0.9", want: mediaJSON},
+		{name: "Tie", accept: "text/plain, application/json", want: mediaJSON},
+		{name: "Type wildcard", accept: "text/*", want: mediaHTML},
+		{name: "Specific range wins", accept: "text/*, text/html;q=0", want: mediaText},
+		{name: "Refused", accept: "application/json;q=0", want: ""},
+		{name: "All refused", accept: "*/*;q=0", want: ""},
+		{name: "Unsupported", accept: "image/png", want: ""},
+		{name: "Invalid range", accept: "json, text/plain;q=0.1", want: mediaText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			assert.Equal(t, negotiate(r), tt.want)
+		})
+	}
+}
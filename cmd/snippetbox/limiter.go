@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// unlockMaxAttempts failed unlocks of a snippet from one client lock
+	// that client out of the snippet for unlockAttemptWindow.
+	unlockMaxAttempts   = 5
+	unlockAttemptWindow = 15 * time.Minute
+
+	// limiterSweepSize is how many keys an attemptLimiter holds before it
+	// forgets the stale ones.
+	limiterSweepSize = 1024
+)
+
+// attemptLimiter counts failed attempts per key in fixed windows and refuses
+// further attempts once a key reaches the maximum, until its window ends.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[string]*attempts
+	now      func() time.Time
+}
+
+type attempts struct {
+	failures int
+	start    time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: map[string]*attempts{},
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether another attempt is allowed for key.
+func (l *attemptLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok || l.expired(a) {
+		return true
+	}
+
+	return a.failures < l.max
+}
+
+// Fail records a failed attempt for key.
+func (l *attemptLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok || l.expired(a) {
+		if len(l.attempts) >= limiterSweepSize {
+			l.sweep()
+		}
+
+		a = &attempts{start: l.now()}
+		l.attempts[key] = a
+	}
+
+	a.failures++
+}
+
+// Reset forgets the failed attempts for key.
+func (l *attemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+func (l *attemptLimiter) expired(a *attempts) bool {
+	return l.now().Sub(a.start) >= l.window
+}
+
+func (l *attemptLimiter) sweep() {
+	for key, a := range l.attempts {
+		if l.expired(a) {
+			delete(l.attempts, key)
+		}
+	}
+}
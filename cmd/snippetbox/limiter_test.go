@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	now := time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC)
+	l := newAttemptLimiter(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, l.Allow("a"), true)
+		l.Fail("a")
+	}
+	assert.Equal(t, l.Allow("a"), false)
+	assert.Equal(t, l.Allow("b"), true)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, l.Allow("a"), true)
+
+	l.Fail("a")
+	l.Fail("a")
+	l.Fail("a")
+	assert.Equal(t, l.Allow("a"), false)
+	l.Reset("a")
+	assert.Equal(t, l.Allow("a"), true)
+}
+
+func TestAttemptLimiterSweep(t *testing.T) {
+	now := time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC)
+	l := newAttemptLimiter(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < limiterSweepSize; i++ {
+		l.Fail(string(rune('a' + i)))
+	}
+	assert.Equal(t, len(l.attempts), limiterSweepSize)
+
+	now = now.Add(time.Minute)
+	l.Fail("fresh")
+	assert.Equal(t, len(l.attempts), 1)
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/postgresstore"
@@ -23,19 +29,46 @@ type Application struct {
 	errLog         *log.Logger
 	snippet        models.Snippets
 	users          models.Users
+	comments       models.Comments
+	stars          models.Stars
+	views          models.Views
+	tokens         models.Tokens
+	webhooks       models.Webhooks
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
 	debug          bool
+	// idAccess also makes public snippets reachable by their numeric ID.
+	// It's off by default, so snippets can't be found by counting IDs
+	// upward.
+	idAccess      bool
+	unlockLimiter *attemptLimiter
+	viewCounter   *viewCounter
+	webhookClient *http.Client
+	// publicURL is the URL the app is reached at, for absolute links, which
+	// mustn't depend on the Host header.
+	publicURL string
 }
 
 func main() {
 	var addr string
 	var dsn string
 	var debug bool
+	var idAccess bool
+	var reapInterval time.Duration
+	var reapBatchSize int
+	var viewFlushInterval time.Duration
+	var webhookInterval time.Duration
+	var publicURL string
 	flag.StringVar(&addr, "addr", ":4000", "HTTP network address")
 	flag.StringVar(&dsn, "dsn", "host=localhost port=5432 user=app_user password=huy2000 dbname=snippetbox sslmode=require search_path=app", "Postgresql datasource name")
 	flag.BoolVar(&debug, "debug", false, "Debug mode")
+	flag.BoolVar(&idAccess, "id-access", false, "Also allow access to public snippets by their numeric ID, not only their slug")
+	flag.DurationVar(&reapInterval, "reap-interval", time.Hour, "How often expired snippets are deleted, 0 to never delete them")
+	flag.IntVar(&reapBatchSize, "reap-batch-size", 500, "How many expired snippets are deleted per query")
+	flag.DurationVar(&viewFlushInterval, "view-flush-interval", time.Minute, "How often snippet views counted in memory are saved")
+	flag.DurationVar(&webhookInterval, "webhook-interval", 10*time.Second, "How often queued webhook deliveries are sent")
+	flag.StringVar(&publicURL, "public-url", "https://localhost:4000", "URL the app is reached at, used in absolute links")
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
@@ -67,10 +100,20 @@ func main() {
 		errLog:         errLog,
 		snippet:        &models.SnippetDB{DB: db},
 		users:          &models.UserDB{DB: db},
+		comments:       &models.CommentDB{DB: db},
+		stars:          &models.StarDB{DB: db},
+		views:          &models.ViewDB{DB: db},
+		tokens:         &models.TokenDB{DB: db},
+		webhooks:       &models.WebhookDB{DB: db},
 		templateCache:  templates,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 		debug:          debug,
+		idAccess:       idAccess,
+		unlockLimiter:  newAttemptLimiter(unlockMaxAttempts, unlockAttemptWindow),
+		viewCounter:    newViewCounter(),
+		webhookClient:  newWebhookClient(isPublicAddr),
+		publicURL:      strings.TrimSuffix(publicURL, "/"),
 	}
 
 	tlsConfig := &tls.Config{
@@ -87,11 +130,68 @@ func main() {
 		TLSConfig:    tlsConfig,
 	}
 
-	infoLog.Printf("Starting server on %s\n", addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errLog.Fatal(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if reapBatchSize <= 0 {
+		errLog.Fatal("reap-batch-size must be positive")
+	}
+	var wg sync.WaitGroup
+	if reapInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.reapExpired(ctx, reapInterval, reapBatchSize)
+		}()
+	}
+
+	if viewFlushInterval <= 0 {
+		errLog.Fatal("view-flush-interval must be positive")
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.flushViewsEvery(ctx, viewFlushInterval)
+	}()
+
+	if webhookInterval <= 0 {
+		errLog.Fatal("webhook-interval must be positive")
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.deliverWebhooksEvery(ctx, webhookInterval)
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		infoLog.Printf("Starting server on %s\n", addr)
+		serveErr <- srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	}()
+
+	select {
+	case err = <-serveErr:
+		errLog.Print(err)
+	case <-ctx.Done():
+		infoLog.Print("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = srv.Shutdown(shutdownCtx)
+		if err != nil {
+			errLog.Print(err)
+		}
+	}
+
+	stop()
+	wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		os.Exit(1)
+	}
 }
 
+// shutdownTimeout is how long in-flight requests get to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func openDB(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
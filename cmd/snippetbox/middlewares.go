@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
 )
 
@@ -21,7 +25,7 @@ func (app *Application) recoverFromPanic(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -41,6 +45,15 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// plainErrors makes errors plain text unless the client prefers another
+// format, for routes serving raw content.
+func plainErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), plainErrorsCtxKey, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !app.isAuthenticated(r) {
@@ -76,15 +89,88 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 
 		exists, err := app.users.Exists(id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedCtxKey, true)
+			ctx = context.WithValue(ctx, userIDCtxKey, id)
 			r = r.WithContext(ctx)
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticateAPI authenticates API requests which carry a personal access
+// token as a Bearer token. Requests without a token go on anonymously, while
+// unknown tokens are refused.
+func (app *Application) authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plaintext, ok := bearerToken(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := app.tokens.Authenticate(plaintext)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.apiUnauthorized(w)
+			return
+		}
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tokenCtxKey, token)
+		ctx = context.WithValue(ctx, isAuthenticatedCtxKey, true)
+		ctx = context.WithValue(ctx, userIDCtxKey, token.UserID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// requireScope refuses requests authenticated with a personal access token
+// which wasn't granted scope. Anonymous requests go on, to be checked by the
+// handler.
+func (app *Application) requireScope(scope string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(tokenCtxKey).(*models.Token)
+			if ok && !token.HasScope(scope) {
+				app.apiError(w, http.StatusForbidden, fmt.Sprintf("The token needs the %s scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *Application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiUnauthorized(w)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *Application) apiUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+	app.apiError(w, http.StatusUnauthorized, "Valid credentials are required")
+}
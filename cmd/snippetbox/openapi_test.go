@@ -0,0 +1,520 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+	"github.com/huytran2000-hcmus/snippetbox/ui"
+)
+
+// openAPIDoc holds the parts of the OpenAPI document the tests check
+// responses against.
+type openAPIDoc struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas   map[string]*jsonSchema     `json:"schemas"`
+		Responses map[string]openAPIResponse `json:"responses"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string `json:"operationId"`
+	RequestBody *struct {
+		Content map[string]openAPIMedia `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Ref     string                  `json:"$ref"`
+	Content map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIMedia struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+// jsonSchema is the subset of the OpenAPI schema objects the document uses.
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Format     string                 `json:"format"`
+	Nullable   bool                   `json:"nullable"`
+	Enum       []any                  `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	// AdditionalProperties is either a boolean or a schema.
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+}
+
+// openAPIRoute is a documented operation.
+type openAPIRoute struct {
+	Method string
+	Path   string
+	openAPIOperation
+}
+
+func loadOpenAPI(t *testing.T) *openAPIDoc {
+	t.Helper()
+
+	var doc openAPIDoc
+	err := json.Unmarshal(ui.OpenAPI, &doc)
+	if err != nil {
+		t.Fatalf("parse openapi.json: %s", err)
+	}
+
+	return &doc
+}
+
+// operations returns the documented operations by their ID.
+func (doc *openAPIDoc) operations() map[string]openAPIRoute {
+	ops := map[string]openAPIRoute{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			ops[op.OperationID] = openAPIRoute{Method: strings.ToUpper(method), Path: path, openAPIOperation: op}
+		}
+	}
+
+	return ops
+}
+
+func (doc *openAPIDoc) response(r openAPIResponse) openAPIResponse {
+	if r.Ref != "" {
+		return doc.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+	}
+
+	return r
+}
+
+func (doc *openAPIDoc) schema(s *jsonSchema) *jsonSchema {
+	if s.Ref != "" {
+		return doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+
+	return s
+}
+
+// validate checks that v, decoded with UseNumber, matches schema s. It
+// returns the first mismatch found, naming it by its JSON path.
+func (doc *openAPIDoc) validate(s *jsonSchema, v any, path string) error {
+	s = doc.schema(s)
+	if s == nil {
+		return fmt.Errorf("%s: unknown schema", path)
+	}
+
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: must not be null", path)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v isn't one of %v", path, v, s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: %v must be a string", path, v)
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: %q must be a date-time", path, str)
+			}
+		case "uri":
+			if u, err := url.Parse(str); err != nil || !u.IsAbs() {
+				return fmt.Errorf("%s: %q must be an absolute URI", path, str)
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: %v must be a number", path, v)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: %s must be a number", path, n)
+		}
+		if _, err := n.Int64(); s.Type == "integer" && err != nil {
+			return fmt.Errorf("%s: %s must be an integer", path, n)
+		}
+		if s.Minimum != nil && f < *s.Minimum || s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: %s is out of range", path, n)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: %v must be a boolean", path, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: %v must be an array", path, v)
+		}
+		for i, item := range items {
+			err := doc.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: %v must be an object", path, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: misses the required %q property", path, name)
+			}
+		}
+
+		var additional *jsonSchema
+		closed := string(s.AdditionalProperties) == "false"
+		if len(s.AdditionalProperties) > 0 && !closed && string(s.AdditionalProperties) != "true" {
+			err := json.Unmarshal(s.AdditionalProperties, &additional)
+			if err != nil {
+				return fmt.Errorf("%s: invalid additionalProperties: %s", path, err)
+			}
+		}
+
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			switch {
+			case ok:
+			case closed:
+				return fmt.Errorf("%s: has the undocumented %q property", path, name)
+			case additional != nil:
+				prop = additional
+			default:
+				continue
+			}
+			err := doc.validate(prop, obj[name], path+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+
+	return nil
+}
+
+func decodeJSON(body string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// pathTemplateRX matches the parameters of an OpenAPI path template.
+var pathTemplateRX = regexp.MustCompile(`\{[^}/]+\}`)
+
+// routeParamRX matches the named and catch-all parameters of a router path.
+var routeParamRX = regexp.MustCompile(`[:*]([^/]+)`)
+
+// matchesPathTemplate reports whether a request path fits a path template.
+// The last parameter of a catch-all template spans the rest of the path.
+func matchesPathTemplate(template string, path string, catchAll bool) bool {
+	want, got := strings.Split(template, "/"), strings.Split(path, "/")
+	if catchAll && len(got) > len(want) {
+		got = append(got[:len(want)-1], strings.Join(got[len(want)-1:], "/"))
+	}
+	if len(want) != len(got) {
+		return false
+	}
+
+	for i := range want {
+		if pathTemplateRX.MatchString(want[i]) {
+			if got[i] == "" {
+				return false
+			}
+		} else if want[i] != got[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestOpenAPI(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	doc := loadOpenAPI(t)
+	ops := doc.operations()
+
+	catchAll := map[string]bool{}
+	for _, r := range app.router().routes {
+		if strings.Contains(r.Path, "*") {
+			catchAll[routeParamRX.ReplaceAllString(r.Path, "{$1}")] = true
+		}
+	}
+
+	const valid = `{"title": "Hello", "files": [{"content": "Hi"}], "tags": ["go"]}`
+
+	tests := []struct {
+		operationID string
+		path        string
+		body        string
+		user        string
+		token       string
+		accept      string
+		wantStatus  int
+	}{
+		{operationID: "getHome", path: "/", wantStatus: http.StatusOK},
+		{operationID: "getHome", path: "/?page=1", accept: "application/json", wantStatus: http.StatusOK},
+		{operationID: "getHome", path: "/", accept: "text/plain", wantStatus: http.StatusOK},
+		{operationID: "getHome", path: "/?page=0", accept: "application/json", wantStatus: http.StatusBadRequest},
+		{operationID: "getHome", path: "/", accept: "image/png", wantStatus: http.StatusNotAcceptable},
+		{operationID: "viewSnippet", path: "/snippet/view/pondXy12Ab", accept: "application/json", wantStatus: http.StatusOK},
+		{operationID: "viewSnippet", path: "/snippet/view/bundleQ7Rt", accept: "text/plain", wantStatus: http.StatusOK},
+		{operationID: "viewSnippet", path: "/snippet/view/lockedZ9Kq", accept: "application/json", wantStatus: http.StatusForbidden},
+		{operationID: "viewSnippet", path: "/snippet/view/forest34Cd", accept: "application/json", wantStatus: http.StatusNotFound},
+		{operationID: "viewSnippetBySlug", path: "/s/pondXy12Ab", wantStatus: http.StatusOK},
+		{operationID: "viewSnippetBySlug", path: "/s/pondXy12Ab", accept: "application/json", wantStatus: http.StatusOK},
+		{operationID: "viewSnippetBySlug", path: "/s/missing000", accept: "text/plain", wantStatus: http.StatusNotFound},
+
+		{operationID: "getAbout", path: "/about", wantStatus: http.StatusOK},
+		{operationID: "getSnippetHistory", path: "/snippet/view/pondXy12Ab/history", wantStatus: http.StatusOK},
+		{operationID: "getSnippetHistory", path: "/snippet/view/missing000/history", accept: "application/json", wantStatus: http.StatusNotFound},
+		{operationID: "getSnippetRevision", path: "/snippet/view/pondXy12Ab/rev/1", wantStatus: http.StatusOK},
+		{operationID: "getSnippetRevision", path: "/snippet/view/pondXy12Ab/rev/99", wantStatus: http.StatusNotFound},
+		{operationID: "getSnippetDiff", path: "/snippet/view/pondXy12Ab/diff?from=1&to=2", wantStatus: http.StatusOK},
+		{operationID: "getSnippetDiff", path: "/snippet/view/pondXy12Ab/diff?from=x", accept: "text/plain", wantStatus: http.StatusBadRequest},
+		{operationID: "searchSnippets", path: "/snippet/search?q=pond", wantStatus: http.StatusOK},
+		{operationID: "searchSnippets", path: "/snippet/search?q=pond&page=0", wantStatus: http.StatusBadRequest},
+		{operationID: "getTag", path: "/tag/haiku", wantStatus: http.StatusOK},
+		{operationID: "getTag", path: "/tag/haiku?page=0", accept: "application/json", wantStatus: http.StatusBadRequest},
+		{operationID: "getTags", path: "/tags", wantStatus: http.StatusOK},
+		{operationID: "unlockSnippet", path: "/snippet/unlock/lockedZ9Kq", wantStatus: http.StatusBadRequest},
+		{operationID: "getSnippetRaw", path: "/snippet/raw/bundleQ7Rt?file=main.go", wantStatus: http.StatusOK},
+		{operationID: "getSnippetRaw", path: "/snippet/raw/lockedZ9Kq", wantStatus: http.StatusForbidden},
+		{operationID: "getSnippetRaw", path: "/snippet/raw/missing000", wantStatus: http.StatusNotFound},
+		{operationID: "downloadSnippet", path: "/snippet/download/pondXy12Ab", wantStatus: http.StatusOK},
+		{operationID: "downloadSnippet", path: "/snippet/download/bundleQ7Rt", wantStatus: http.StatusOK},
+		{operationID: "downloadSnippet", path: "/snippet/download/missing000", accept: "application/json", wantStatus: http.StatusNotFound},
+		{operationID: "getSignup", path: "/user/signup", wantStatus: http.StatusOK},
+		{operationID: "signup", path: "/user/signup", wantStatus: http.StatusBadRequest},
+		{operationID: "getLogin", path: "/user/login", wantStatus: http.StatusOK},
+		{operationID: "login", path: "/user/login", wantStatus: http.StatusBadRequest},
+
+		// Without a session, the pages of the account redirect to the login
+		// page and the forms fail the CSRF check.
+		{operationID: "getCreateSnippet", path: "/snippet/create", wantStatus: http.StatusSeeOther},
+		{operationID: "createSnippetForm", path: "/snippet/create", wantStatus: http.StatusBadRequest},
+		{operationID: "getEditSnippet", path: "/snippet/edit/pondXy12Ab", wantStatus: http.StatusSeeOther},
+		{operationID: "editSnippet", path: "/snippet/edit/pondXy12Ab", wantStatus: http.StatusBadRequest},
+		{operationID: "deleteSnippetForm", path: "/snippet/delete/pondXy12Ab", wantStatus: http.StatusBadRequest},
+		{operationID: "forkSnippet", path: "/snippet/fork/pondXy12Ab", wantStatus: http.StatusBadRequest},
+		{operationID: "getSnippetStats", path: "/snippet/stats/pondXy12Ab", wantStatus: http.StatusSeeOther},
+		{operationID: "starSnippet", path: "/snippet/star/pondXy12Ab", wantStatus: http.StatusBadRequest},
+		{operationID: "commentSnippet", path: "/snippet/comment/pondXy12Ab", wantStatus: http.StatusBadRequest},
+		{operationID: "deleteComment", path: "/comment/delete/1", wantStatus: http.StatusBadRequest},
+		{operationID: "logout", path: "/user/logout", wantStatus: http.StatusBadRequest},
+		{operationID: "getAccount", path: "/account/view", wantStatus: http.StatusSeeOther},
+		{operationID: "getStarredSnippets", path: "/account/starred", wantStatus: http.StatusSeeOther},
+		{operationID: "getTokens", path: "/account/tokens", wantStatus: http.StatusSeeOther},
+		{operationID: "createToken", path: "/account/tokens", wantStatus: http.StatusBadRequest},
+		{operationID: "revokeToken", path: "/account/tokens/revoke/1", wantStatus: http.StatusBadRequest},
+		{operationID: "getWebhooks", path: "/account/webhooks", wantStatus: http.StatusSeeOther},
+		{operationID: "createWebhook", path: "/account/webhooks", wantStatus: http.StatusBadRequest},
+		{operationID: "deleteWebhook", path: "/account/webhooks/delete/1", wantStatus: http.StatusBadRequest},
+		{operationID: "getPasswordUpdate", path: "/account/password/update", wantStatus: http.StatusSeeOther},
+		{operationID: "updatePassword", path: "/account/password/update", wantStatus: http.StatusBadRequest},
+
+		{operationID: "ping", path: "/ping", wantStatus: http.StatusOK},
+		{operationID: "getOpenAPI", path: "/openapi.json", wantStatus: http.StatusOK},
+		{operationID: "getStaticFile", path: "/static/css/main.css", wantStatus: http.StatusOK},
+		{operationID: "getStaticFile", path: "/static/img/logo.png", wantStatus: http.StatusOK},
+		{operationID: "getStaticFile", path: "/static/css/missing.css", wantStatus: http.StatusNotFound},
+		{operationID: "getAtomFeed", path: "/feed.atom", wantStatus: http.StatusOK},
+		{operationID: "getAtomFeed", path: "/feed.atom?user=0", accept: "application/json", wantStatus: http.StatusNotFound},
+		{operationID: "getRSSFeed", path: "/feed.rss?tag=haiku", wantStatus: http.StatusOK},
+		{operationID: "getRSSFeed", path: "/feed.rss?tag=-", wantStatus: http.StatusNotFound},
+
+		{operationID: "listSnippets", path: "/api/v1/snippets", wantStatus: http.StatusOK},
+		{operationID: "listSnippets", path: "/api/v1/snippets?page=1&page_size=5", token: "sbp_bobreadonly", wantStatus: http.StatusOK},
+		{operationID: "listSnippets", path: "/api/v1/snippets?page_size=1000", wantStatus: http.StatusBadRequest},
+		{operationID: "listSnippets", path: "/api/v1/snippets", token: "sbp_nope", wantStatus: http.StatusUnauthorized},
+		{operationID: "listSnippets", path: "/api/v1/snippets", token: "sbp_bobwriteonly", wantStatus: http.StatusForbidden},
+
+		{operationID: "createSnippet", path: "/api/v1/snippets", body: valid, user: "alice", wantStatus: http.StatusCreated},
+		{operationID: "createSnippet", path: "/api/v1/snippets", body: `{"title": "Hello", "files": [{"name": "a.go", "language": "go", "content": "package a"}], "expires": "never", "visibility": "private"}`, token: "sbp_alicereadwrite", wantStatus: http.StatusCreated},
+		{operationID: "createSnippet", path: "/api/v1/snippets", body: `{"title": `, user: "alice", wantStatus: http.StatusBadRequest},
+		{operationID: "createSnippet", path: "/api/v1/snippets", body: valid, wantStatus: http.StatusUnauthorized},
+		{operationID: "createSnippet", path: "/api/v1/snippets", body: valid, token: "sbp_bobreadonly", wantStatus: http.StatusForbidden},
+		{operationID: "createSnippet", path: "/api/v1/snippets", body: `{"title": "", "expires": "keep"}`, user: "alice", wantStatus: http.StatusUnprocessableEntity},
+
+		{operationID: "getSnippet", path: "/api/v1/snippets/pondXy12Ab", wantStatus: http.StatusOK},
+		{operationID: "getSnippet", path: "/api/v1/snippets/bundleQ7Rt", wantStatus: http.StatusOK},
+		{operationID: "getSnippet", path: "/api/v1/snippets/burnMe78Gh", user: "alice", wantStatus: http.StatusOK},
+		{operationID: "getSnippet", path: "/api/v1/snippets/forest34Cd", token: "sbp_alicereadwrite", wantStatus: http.StatusOK},
+		{operationID: "getSnippet", path: "/api/v1/snippets/pondXy12Ab", token: "sbp_nope", wantStatus: http.StatusUnauthorized},
+		{operationID: "getSnippet", path: "/api/v1/snippets/lockedZ9Kq", wantStatus: http.StatusForbidden},
+		{operationID: "getSnippet", path: "/api/v1/snippets/forest34Cd", wantStatus: http.StatusNotFound},
+
+		{operationID: "updateSnippet", path: "/api/v1/snippets/pondXy12Ab", body: `{"title": "Renamed", "tags": []}`, user: "alice", wantStatus: http.StatusOK},
+		{operationID: "updateSnippet", path: "/api/v1/snippets/pondXy12Ab", body: `{"colour": "red"}`, user: "alice", wantStatus: http.StatusBadRequest},
+		{operationID: "updateSnippet", path: "/api/v1/snippets/pondXy12Ab", body: `{"title": "Renamed"}`, wantStatus: http.StatusUnauthorized},
+		{operationID: "updateSnippet", path: "/api/v1/snippets/pondXy12Ab", body: `{"title": "Renamed"}`, user: "bob", wantStatus: http.StatusForbidden},
+		{operationID: "updateSnippet", path: "/api/v1/snippets/missing000", body: `{"title": "Renamed"}`, user: "alice", wantStatus: http.StatusNotFound},
+		{operationID: "updateSnippet", path: "/api/v1/snippets/pondXy12Ab", body: `{"visibility": "secret"}`, user: "alice", wantStatus: http.StatusUnprocessableEntity},
+
+		{operationID: "deleteSnippet", path: "/api/v1/snippets/pondXy12Ab", user: "alice", wantStatus: http.StatusNoContent},
+		{operationID: "deleteSnippet", path: "/api/v1/snippets/pondXy12Ab", wantStatus: http.StatusUnauthorized},
+		{operationID: "deleteSnippet", path: "/api/v1/snippets/pondXy12Ab", user: "bob", wantStatus: http.StatusForbidden},
+		{operationID: "deleteSnippet", path: "/api/v1/snippets/missing000", user: "alice", wantStatus: http.StatusNotFound},
+	}
+
+	tested := map[string]bool{}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d %s", tt.operationID, tt.wantStatus, tt.path), func(t *testing.T) {
+			op, ok := ops[tt.operationID]
+			if !ok {
+				t.Fatalf("operation %q isn't documented", tt.operationID)
+			}
+			tested[tt.operationID] = true
+
+			u, err := url.Parse(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !matchesPathTemplate(op.Path, u.Path, catchAll[op.Path]) {
+				t.Fatalf("path %q doesn't fit the documented path %q", u.Path, op.Path)
+			}
+
+			if tt.body != "" && op.RequestBody != nil {
+				// Invalid bodies are sent on purpose to get a 400 or a 422.
+				invalid := tt.wantStatus == http.StatusBadRequest || tt.wantStatus == http.StatusUnprocessableEntity
+				if v, err := decodeJSON(tt.body); err == nil && !invalid {
+					err = doc.validate(op.RequestBody.Content["application/json"].Schema, v, "request")
+					if err != nil {
+						t.Fatalf("the request body doesn't match its schema: %s", err)
+					}
+				}
+			}
+
+			req := newAPIRequest(op.Method, tt.path, tt.body, tt.user)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			status, header, body := ts.Do(t, req)
+			assert.Equal(t, status, tt.wantStatus)
+
+			documented, ok := op.Responses[strconv.Itoa(status)]
+			if !ok {
+				t.Fatalf("status %d isn't documented", status)
+			}
+			resp := doc.response(documented)
+
+			if len(resp.Content) == 0 {
+				assert.Equal(t, body, "")
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+			if err != nil {
+				t.Fatalf("invalid Content-Type %q: %s", header.Get("Content-Type"), err)
+			}
+			media, ok := resp.Content[mediaType]
+			if !ok {
+				t.Fatalf("Content-Type %q isn't documented", mediaType)
+			}
+
+			var v any = body
+			if mediaType == "application/json" {
+				v, err = decodeJSON(body)
+				if err != nil {
+					t.Fatalf("invalid JSON body: %s", err)
+				}
+			} else if media.Schema.Type == "string" {
+				v = strings.TrimSuffix(body, "\n")
+			}
+			err = doc.validate(media.Schema, v, "response")
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	for id := range ops {
+		if !tested[id] {
+			t.Errorf("operation %q isn't tested", id)
+		}
+	}
+}
+
+// TestOpenAPIRoutes checks that each route of the router is documented, and
+// that each documented operation is routed.
+func TestOpenAPIRoutes(t *testing.T) {
+	app := newTestApplication(t)
+
+	routed := map[string]bool{}
+	for _, r := range app.router().routes {
+		routed[r.Method+" "+routeParamRX.ReplaceAllString(r.Path, "{$1}")] = true
+	}
+
+	documented := map[string]bool{}
+	for path, item := range loadOpenAPI(t).Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for r := range routed {
+		if !documented[r] {
+			t.Errorf("route %q isn't documented", r)
+		}
+	}
+	for r := range documented {
+		if !routed[r] {
+			t.Errorf("documented route %q isn't routed", r)
+		}
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	status, header, body := ts.Get(t, "/openapi.json")
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+	assert.Equal(t, body, strings.TrimSpace(string(ui.OpenAPI)))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// reapExpired deletes expired snippets every interval until ctx is done.
+func (app *Application) reapExpired(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.reapOnce(ctx, batchSize)
+		}
+	}
+}
+
+// reapOnce deletes the snippets which have expired so far in batches of
+// batchSize rows, so a large backlog doesn't hold locks for long, and
+// returns how many were deleted.
+func (app *Application) reapOnce(ctx context.Context, batchSize int) int {
+	before := time.Now()
+	total := 0
+	for {
+		n, err := app.snippet.DeleteExpired(ctx, before, batchSize)
+		total += n
+		if err != nil {
+			if ctx.Err() == nil {
+				app.errLog.Print(err)
+			}
+			break
+		}
+		if n < batchSize {
+			break
+		}
+	}
+
+	app.infoLog.Printf("Reaper deleted %d expired snippets\n", total)
+	return total
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+	"github.com/huytran2000-hcmus/snippetbox/internal/mock"
+)
+
+// expiringSnippets pretends there are expired snippets left to delete.
+type expiringSnippets struct {
+	mock.StubSnippets
+	expired int
+	calls   int
+	err     error
+}
+
+func (s *expiringSnippets) DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	n := s.expired
+	if n > limit {
+		n = limit
+	}
+	s.expired -= n
+	return n, nil
+}
+
+func TestReapOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		expired   int
+		err       error
+		wantTotal int
+		wantCalls int
+	}{
+		{
+			name:      "Nothing expired",
+			expired:   0,
+			wantTotal: 0,
+			wantCalls: 1,
+		},
+		{
+			name:      "Less than a batch",
+			expired:   3,
+			wantTotal: 3,
+			wantCalls: 1,
+		},
+		{
+			name:      "Several batches",
+			expired:   25,
+			wantTotal: 25,
+			wantCalls: 3,
+		},
+		{
+			name:      "Exact batches",
+			expired:   20,
+			wantTotal: 20,
+			wantCalls: 3,
+		},
+		{
+			name:      "Error",
+			expired:   25,
+			err:       errors.New("connection refused"),
+			wantTotal: 0,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			snippets := &expiringSnippets{expired: tt.expired, err: tt.err}
+			app.snippet = snippets
+
+			total := app.reapOnce(context.Background(), 10)
+
+			assert.Equal(t, total, tt.wantTotal)
+			assert.Equal(t, snippets.calls, tt.wantCalls)
+		})
+	}
+}
+
+func TestReapExpiredStops(t *testing.T) {
+	app := newTestApplication(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		app.reapExpired(ctx, time.Millisecond, 10)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reaper didn't stop after its context was cancelled")
+	}
+}
@@ -3,23 +3,66 @@ package main
 import (
 	"net/http"
 
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
 	"github.com/huytran2000-hcmus/snippetbox/ui"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 )
 
+// route is a method and a path registered on the router.
+type route struct {
+	Method string
+	Path   string
+}
+
+// routeTable is an httprouter.Router which records its routes, so that they
+// can be checked against the OpenAPI document.
+type routeTable struct {
+	*httprouter.Router
+	routes []route
+}
+
+func (rt *routeTable) Handler(method, path string, handler http.Handler) {
+	rt.routes = append(rt.routes, route{Method: method, Path: path})
+	rt.Router.Handler(method, path, handler)
+}
+
+func (rt *routeTable) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	rt.Handler(method, path, handler)
+}
+
 func (app *Application) routes() http.Handler {
-	router := httprouter.New()
+	standardMW := alice.New(app.recoverFromPanic, app.logRequest, secureHeaders)
+	return standardMW.Then(app.router())
+}
+
+func (app *Application) router() *routeTable {
+	router := &routeTable{Router: httprouter.New()}
 
 	fileServer := http.FileServer(http.FS(ui.Files))
 	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
 
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	router.HandlerFunc(http.MethodGet, "/openapi.json", openAPI)
+	router.HandlerFunc(http.MethodGet, "/feed.atom", app.feedAtom)
+	router.HandlerFunc(http.MethodGet, "/feed.rss", app.feedRSS)
 
 	statefulMW := alice.New(app.sessionManager.LoadAndSave, CSRFPrevent, app.authenticate)
 	router.Handler(http.MethodGet, "/", statefulMW.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/about", statefulMW.ThenFunc(app.about))
 	router.Handler(http.MethodGet, "/snippet/view/:id", statefulMW.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", statefulMW.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/view/:id/rev/:n", statefulMW.ThenFunc(app.snippetRevision))
+	router.Handler(http.MethodGet, "/snippet/view/:id/diff", statefulMW.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodGet, "/s/:slug", statefulMW.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/search", statefulMW.ThenFunc(app.snippetSearch))
+	router.Handler(http.MethodGet, "/tag/:name", statefulMW.ThenFunc(app.tagView))
+	router.Handler(http.MethodGet, "/tags", statefulMW.ThenFunc(app.tags))
+	router.Handler(http.MethodPost, "/snippet/unlock/:id", statefulMW.ThenFunc(app.snippetUnlock))
+
+	rawMW := statefulMW.Append(plainErrors)
+	router.Handler(http.MethodGet, "/snippet/raw/:id", rawMW.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", rawMW.ThenFunc(app.snippetDownload))
 
 	router.Handler(http.MethodGet, "/user/signup", statefulMW.ThenFunc(app.userSignupForm))
 	router.Handler(http.MethodPost, "/user/signup", statefulMW.ThenFunc(app.userSignup))
@@ -29,15 +72,39 @@ func (app *Application) routes() http.Handler {
 	protectedMW := statefulMW.Append(app.requireAuthentication)
 	router.Handler(http.MethodGet, "/snippet/create", protectedMW.ThenFunc(app.snippetCreateForm))
 	router.Handler(http.MethodPost, "/snippet/create", protectedMW.ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protectedMW.ThenFunc(app.snippetEditForm))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protectedMW.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protectedMW.ThenFunc(app.snippetDelete))
+	router.Handler(http.MethodPost, "/snippet/fork/:id", protectedMW.ThenFunc(app.snippetFork))
+	router.Handler(http.MethodGet, "/snippet/stats/:id", protectedMW.ThenFunc(app.snippetStats))
+	router.Handler(http.MethodPost, "/snippet/star/:id", protectedMW.ThenFunc(app.snippetStar))
+	router.Handler(http.MethodPost, "/snippet/comment/:id", protectedMW.ThenFunc(app.commentCreate))
+	router.Handler(http.MethodPost, "/comment/delete/:id", protectedMW.ThenFunc(app.commentDelete))
 	router.Handler(http.MethodPost, "/user/logout", protectedMW.ThenFunc(app.userLogout))
 	router.Handler(http.MethodGet, "/account/view", protectedMW.ThenFunc(app.account))
+	router.Handler(http.MethodGet, "/account/starred", protectedMW.ThenFunc(app.accountStarred))
+	router.Handler(http.MethodGet, "/account/tokens", protectedMW.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protectedMW.ThenFunc(app.accountTokenCreate))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protectedMW.ThenFunc(app.accountTokenRevoke))
+	router.Handler(http.MethodGet, "/account/webhooks", protectedMW.ThenFunc(app.accountWebhooks))
+	router.Handler(http.MethodPost, "/account/webhooks", protectedMW.ThenFunc(app.accountWebhookCreate))
+	router.Handler(http.MethodPost, "/account/webhooks/delete/:id", protectedMW.ThenFunc(app.accountWebhookDelete))
 	router.Handler(http.MethodGet, "/account/password/update", protectedMW.ThenFunc(app.accountPasswordUpdateForm))
 	router.Handler(http.MethodPost, "/account/password/update", protectedMW.ThenFunc(app.accountPasswordUpdate))
 
-	standardMW := alice.New(app.recoverFromPanic, app.logRequest, secureHeaders)
+	apiMW := alice.New(app.authenticateAPI)
+	readAPIMW := apiMW.Append(app.requireScope(models.ScopeSnippetsRead))
+	router.Handler(http.MethodGet, "/api/v1/snippets", readAPIMW.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", readAPIMW.ThenFunc(app.apiSnippetView))
+
+	writeAPIMW := apiMW.Append(app.requireAPIAuthentication, app.requireScope(models.ScopeSnippetsWrite))
+	router.Handler(http.MethodPost, "/api/v1/snippets", writeAPIMW.ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodPatch, "/api/v1/snippets/:id", writeAPIMW.ThenFunc(app.apiSnippetUpdate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:id", writeAPIMW.ThenFunc(app.apiSnippetDelete))
+
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.notFound(w)
+		app.notFound(w, r)
 	})
 
-	return standardMW.Then(router)
+	return router
 }
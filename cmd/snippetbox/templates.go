@@ -4,25 +4,202 @@ import (
 	"fmt"
 	"html/template"
 	"io/fs"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/huytran2000-hcmus/snippetbox/internal/highlight"
+	"github.com/huytran2000-hcmus/snippetbox/internal/markdown"
 	"github.com/huytran2000-hcmus/snippetbox/internal/models"
 	"github.com/huytran2000-hcmus/snippetbox/ui"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 type templateData struct {
 	Snippet         *models.Snippet
 	Snippets        []models.Snippet
+	SearchResults   []models.SearchResult
+	Revisions       []models.Revision
+	Revision        *models.Revision
+	DiffFrom        *models.Revision
+	DiffTo          *models.Revision
+	Diff            []diffLine
+	Query           string
+	Pagination      *pagination
+	Tag             string
+	TagCloud        []cloudTag
+	Comments        []threadedComment
+	Starred         bool
+	MostStarred     []models.Snippet
+	ViewStats       *models.ViewStats
+	Tokens          []models.Token
+	NewToken        string
+	Webhooks        []models.Webhook
+	Attempts        []models.DeliveryAttempt
+	Error           *errorPage
 	User            *models.User
 	CurrentYear     int
 	Form            interface{}
 	FlashMessage    string
 	IsAuthenticated bool
+	AuthenticatedID int
 	CSRFToken       string
 }
 
+// errorPage is what the error page shows. Message is the status text, or the
+// stack trace of server errors in debug mode.
+type errorPage struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+type pagination struct {
+	Page     int
+	PageSize int
+	Total    int
+	url      url.URL
+}
+
+func (p *pagination) LastPage() int {
+	if p.Total == 0 {
+		return 1
+	}
+
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+func (p *pagination) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p *pagination) HasNext() bool {
+	return p.Page < p.LastPage()
+}
+
+func (p *pagination) PrevURL() string {
+	return p.pageURL(p.Page - 1)
+}
+
+func (p *pagination) NextURL() string {
+	return p.pageURL(p.Page + 1)
+}
+
+func (p *pagination) pageURL(page int) string {
+	u := p.url
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	if p.PageSize != defaultPageSize {
+		query.Set("page_size", strconv.Itoa(p.PageSize))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
+
+// threadedComment is a comment placed in its thread. Depth is 0 for top level
+// comments and grows by one with each level of replies, up to
+// maxCommentDepth so deep threads stay readable.
+type threadedComment struct {
+	models.Comment
+	Depth int
+}
+
+const maxCommentDepth = 5
+
+// threadComments orders comments so that replies follow the comment they
+// answer, oldest first at every level. comments must be sorted oldest
+// first. Replies to missing comments are shown as top level comments.
+func threadComments(comments []models.Comment) []threadedComment {
+	ids := map[int]bool{}
+	for _, c := range comments {
+		ids[c.ID] = true
+	}
+
+	replies := map[int][]models.Comment{}
+	for _, c := range comments {
+		parent := c.ParentID
+		if !ids[parent] {
+			parent = 0
+		}
+		replies[parent] = append(replies[parent], c)
+	}
+
+	threaded := make([]threadedComment, 0, len(comments))
+	var walk func(parent int, depth int)
+	walk = func(parent int, depth int) {
+		for _, c := range replies[parent] {
+			threaded = append(threaded, threadedComment{Comment: c, Depth: depth})
+			next := depth + 1
+			if next > maxCommentDepth {
+				next = maxCommentDepth
+			}
+			walk(c.ID, next)
+		}
+	}
+	walk(0, 0)
+
+	return threaded
+}
+
+// lineRefRX matches references to highlighted lines, L12 being the twelfth
+// line of the first file and F2L12 that of the second file.
+var lineRefRX = regexp.MustCompile(`\b(?:F[0-9]+)?L[0-9]+\b`)
+
+// commentBody turns a comment into HTML, escaping it and linking the line
+// references it contains to the lines.
+func commentBody(body string) template.HTML {
+	var b strings.Builder
+	last := 0
+	for _, m := range lineRefRX.FindAllStringIndex(body, -1) {
+		ref := body[m[0]:m[1]]
+		b.WriteString(template.HTMLEscapeString(body[last:m[0]]))
+		fmt.Fprintf(&b, "<a href='#%s'>%s</a>", ref, ref)
+		last = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(body[last:]))
+
+	return template.HTML(b.String())
+}
+
+// cloudTag is a tag of the tag cloud. Its size goes from 1 for the least
+// used tags to maxCloudTagSize for the most used ones.
+type cloudTag struct {
+	models.TagCount
+	Size int
+}
+
+const maxCloudTagSize = 5
+
+func newTagCloud(counts []models.TagCount) []cloudTag {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	least, most := counts[0].Count, counts[0].Count
+	for _, c := range counts {
+		if c.Count < least {
+			least = c.Count
+		}
+		if c.Count > most {
+			most = c.Count
+		}
+	}
+
+	cloud := make([]cloudTag, len(counts))
+	for i, c := range counts {
+		cloud[i] = cloudTag{TagCount: c, Size: 1}
+		if most > least {
+			cloud[i].Size += (c.Count - least) * (maxCloudTagSize - 1) / (most - least)
+		}
+	}
+
+	return cloud
+}
+
 func newTemplateCache() (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
 
@@ -36,9 +213,17 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	}
 
 	funcMap := template.FuncMap{
-		"split_new_line": splitNewLine,
 		"timestamp":      timestamp,
+		"datetime":       datetime,
 		"readable_date":  readableDate,
+		"headline":       headline,
+		"highlight":      highlightCode,
+		"highlight_file": highlightFile,
+		"markdown":       renderMarkdown,
+		"is_markdown":    isMarkdown,
+		"comment_body":   commentBody,
+		"languages":      languages,
+		"sub":            sub,
 	}
 
 	for _, page := range pages {
@@ -56,14 +241,15 @@ func newTemplateCache() (map[string]*template.Template, error) {
 	return cache, nil
 }
 
-func splitNewLine(s string) []string {
-	return strings.Split(s, `\n`)
-}
-
 func timestamp(tm time.Time) string {
 	return tm.UTC().Format("2006-01-02T15:04:05 -0700 MST")
 }
 
+// datetime formats tm for the datetime attribute of a time element.
+func datetime(tm time.Time) string {
+	return tm.UTC().Format(time.RFC3339)
+}
+
 func readableDate(tm time.Time) string {
 	if tm.IsZero() {
 		return ""
@@ -71,3 +257,179 @@ func readableDate(tm time.Time) string {
 
 	return tm.UTC().Format("Monday, 02 Jan 2006 at 15:04:05")
 }
+
+// headline turns a search headline into HTML, escaping the snippet content
+// and wrapping the matched terms in <mark> elements.
+func headline(s string) template.HTML {
+	var b strings.Builder
+	marked := false
+	for {
+		i := strings.IndexAny(s, models.HeadlineStartSel+models.HeadlineStopSel)
+		if i < 0 {
+			b.WriteString(template.HTMLEscapeString(s))
+			break
+		}
+
+		b.WriteString(template.HTMLEscapeString(s[:i]))
+		switch {
+		case s[i:i+1] == models.HeadlineStartSel && !marked:
+			b.WriteString("<mark>")
+			marked = true
+		case s[i:i+1] == models.HeadlineStopSel && marked:
+			b.WriteString("</mark>")
+			marked = false
+		}
+		s = s[i+1:]
+	}
+
+	if marked {
+		b.WriteString("</mark>")
+	}
+
+	return template.HTML(b.String())
+}
+
+func highlightCode(content string, language string) (template.HTML, error) {
+	h, err := highlight.HTML(content, language)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(h), nil
+}
+
+// highlightFile highlights the i-th file of a snippet. The lines of the first
+// file keep the plain line anchors, the others are prefixed with the file
+// number, so #F2L3 is the third line of the second file.
+func highlightFile(content string, language string, i int) (template.HTML, error) {
+	prefix := highlight.LinePrefix
+	if i > 0 {
+		prefix = fmt.Sprintf("F%d%s", i+1, highlight.LinePrefix)
+	}
+
+	h, err := highlight.HTMLWithPrefix(content, language, prefix)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(h), nil
+}
+
+func renderMarkdown(source string) (template.HTML, error) {
+	h, err := markdown.HTML(source)
+	if err != nil {
+		return "", err
+	}
+
+	// markdown.HTML sanitizes its output, so it's safe to embed as is.
+	return template.HTML(h), nil
+}
+
+func isMarkdown(language string) bool {
+	return language == markdown.Language
+}
+
+func sub(a int, b int) int {
+	return a - b
+}
+
+func languages() []highlight.Language {
+	return highlight.Languages
+}
+
+// diffLine is a line of a unified diff. Kind is one of "file", "hunk",
+// "add", "del" or "context" and is used as its CSS class.
+type diffLine struct {
+	Kind string
+	Text string
+}
+
+// unifiedDiff diffs every file of two revisions, matching them by name. A
+// file only one of the revisions has is diffed against an empty one.
+func unifiedDiff(from *models.Revision, to *models.Revision) ([]diffLine, error) {
+	fromFiles := map[string]string{}
+	for _, f := range from.Files {
+		fromFiles[f.Name] = f.Content
+	}
+	toFiles := map[string]string{}
+	for _, f := range to.Files {
+		toFiles[f.Name] = f.Content
+	}
+
+	// Files are diffed in the order of the newer revision, followed by
+	// those it lacks.
+	var names []string
+	for _, f := range to.Files {
+		names = append(names, f.Name)
+	}
+	for _, f := range from.Files {
+		if _, ok := toFiles[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+	}
+
+	var lines []diffLine
+	for _, name := range names {
+		// The file headers are written here rather than by difflib, so
+		// that content lines starting with --- or +++ aren't taken for them.
+		d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:       splitDiffLines(fromFiles[name]),
+			B:       splitDiffLines(toFiles[name]),
+			Context: 3,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("diff revision %d and %d: %s", from.Number, to.Number, err)
+		}
+		if d == "" {
+			continue
+		}
+
+		lines = append(lines,
+			diffLine{Kind: "file", Text: "--- " + revisionFileLabel(from.Number, name)},
+			diffLine{Kind: "file", Text: "+++ " + revisionFileLabel(to.Number, name)},
+		)
+		for _, line := range strings.SplitAfter(d, "\n") {
+			if line == "" {
+				continue
+			}
+
+			kind := "context"
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				kind = "hunk"
+			case strings.HasPrefix(line, "+"):
+				kind = "add"
+			case strings.HasPrefix(line, "-"):
+				kind = "del"
+			}
+			lines = append(lines, diffLine{Kind: kind, Text: strings.TrimSuffix(line, "\n")})
+		}
+	}
+
+	return lines, nil
+}
+
+// splitDiffLines splits content into lines ending in a newline. A missing
+// file has none rather than a blank one, and a trailing newline doesn't
+// start a blank line either.
+func splitDiffLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+
+	return lines
+}
+
+func revisionFileLabel(n int, name string) string {
+	if name == "" {
+		return fmt.Sprintf("revision %d", n)
+	}
+
+	return fmt.Sprintf("%s (revision %d)", name, n)
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
 )
 
 func TestReadableDate(t *testing.T) {
@@ -37,3 +41,155 @@ func TestReadableDate(t *testing.T) {
 		})
 	}
 }
+
+func TestPagination(t *testing.T) {
+	u, err := url.Parse("/search?q=pond&page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &pagination{Page: 2, PageSize: 5, Total: 11, url: *u}
+
+	assert.Equal(t, p.LastPage(), 3)
+	assert.Equal(t, p.HasPrev(), true)
+	assert.Equal(t, p.HasNext(), true)
+	assert.Equal(t, p.PrevURL(), "/search?page=1&page_size=5&q=pond")
+	assert.Equal(t, p.NextURL(), "/search?page=3&page_size=5&q=pond")
+
+	p = &pagination{Page: 1, PageSize: defaultPageSize, Total: 0, url: *u}
+
+	assert.Equal(t, p.LastPage(), 1)
+	assert.Equal(t, p.HasPrev(), false)
+	assert.Equal(t, p.HasNext(), false)
+}
+
+func TestHeadline(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "Marked terms",
+			s:    "An old \x02silent\x03 pond",
+			want: "An old <mark>silent</mark> pond",
+		},
+		{
+			name: "Escaped content",
+			s:    "<script>\x02alert\x03</script>",
+			want: "&lt;script&gt;<mark>alert</mark>&lt;/script&gt;",
+		},
+		{
+			name: "Unbalanced selectors",
+			s:    "\x03An \x02old\x02 pond",
+			want: "An <mark>old pond</mark>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(headline(tt.s)), tt.want)
+		})
+	}
+}
+
+func TestNewTagCloud(t *testing.T) {
+	counts := []models.TagCount{
+		{Name: "go", Count: 9},
+		{Name: "http", Count: 1},
+		{Name: "sql", Count: 5},
+	}
+
+	cloud := newTagCloud(counts)
+
+	assert.Equal(t, len(cloud), 3)
+	assert.Equal(t, cloud[0].Size, 5)
+	assert.Equal(t, cloud[1].Size, 1)
+	assert.Equal(t, cloud[2].Size, 3)
+
+	cloud = newTagCloud([]models.TagCount{{Name: "go", Count: 2}, {Name: "sql", Count: 2}})
+	assert.Equal(t, cloud[0].Size, 1)
+	assert.Equal(t, cloud[1].Size, 1)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := &models.Revision{Number: 1, Files: []models.SnippetFile{
+		{Name: "a.sql", Content: "-- old comment\nSELECT 1;\n"},
+		{Name: "same.txt", Content: "unchanged\n"},
+	}}
+	to := &models.Revision{Number: 2, Files: []models.SnippetFile{
+		{Name: "a.sql", Content: "SELECT 1;\n---\nkey: value\n"},
+		{Name: "same.txt", Content: "unchanged\n"},
+	}}
+
+	lines, err := unifiedDiff(from, to)
+	assert.Equal(t, err, nil)
+
+	want := []diffLine{
+		{Kind: "file", Text: "--- a.sql (revision 1)"},
+		{Kind: "file", Text: "+++ a.sql (revision 2)"},
+		{Kind: "hunk", Text: "@@ -1,2 +1,3 @@"},
+		{Kind: "del", Text: "--- old comment"},
+		{Kind: "context", Text: " SELECT 1;"},
+		{Kind: "add", Text: "+---"},
+		{Kind: "add", Text: "+key: value"},
+	}
+	assert.Equal(t, len(lines), len(want))
+	for i := range want {
+		assert.Equal(t, lines[i], want[i])
+	}
+}
+
+func TestThreadComments(t *testing.T) {
+	comments := []models.Comment{
+		{ID: 1},
+		{ID: 2, ParentID: 1},
+		{ID: 3},
+		{ID: 4, ParentID: 2},
+		{ID: 5, ParentID: 1},
+		{ID: 6, ParentID: 42},
+	}
+
+	threaded := threadComments(comments)
+
+	var got []string
+	for _, c := range threaded {
+		got = append(got, fmt.Sprintf("%d:%d", c.ID, c.Depth))
+	}
+	assert.Equal(t, strings.Join(got, " "), "1:0 2:1 4:2 5:1 3:0 6:0")
+}
+
+func TestCommentBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "Line reference",
+			body: "L12 is wrong",
+			want: "<a href='#L12'>L12</a> is wrong",
+		},
+		{
+			name: "File line reference",
+			body: "see F2L3.",
+			want: "see <a href='#F2L3'>F2L3</a>.",
+		},
+		{
+			name: "Not a reference",
+			body: "HTML5 and XL12",
+			want: "HTML5 and XL12",
+		},
+		{
+			name: "Escaped",
+			body: "<script>L1</script>",
+			want: "&lt;script&gt;<a href='#L1'>L1</a>&lt;/script&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(commentBody(tt.body)), tt.want)
+		})
+	}
+}
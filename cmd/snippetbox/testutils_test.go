@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/netip"
 	"net/url"
 	"regexp"
 	"testing"
@@ -36,12 +37,27 @@ func newTestApplication(t *testing.T) *Application {
 		errLog:         log.New(io.Discard, "", 0),
 		snippet:        &mock.StubSnippets{},
 		users:          &mock.StubUsers{},
+		comments:       &mock.StubComments{},
+		stars:          &mock.StubStars{},
+		views:          &mock.StubViews{},
+		tokens:         &mock.StubTokens{},
+		webhooks:       &mock.StubWebhooks{},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newAttemptLimiter(unlockMaxAttempts, unlockAttemptWindow),
+		viewCounter:    newViewCounter(),
+		webhookClient:  newWebhookClient(allowAnyAddr),
+		publicURL:      "https://snippetbox.example.com",
 	}
 }
 
+// allowAnyAddr lets the webhook client of tests reach receivers started by
+// httptest, which listen on the loopback address.
+func allowAnyAddr(addr netip.Addr) bool {
+	return true
+}
+
 type testServer struct {
 	*httptest.Server
 }
@@ -97,6 +113,33 @@ func (ts *testServer) PostForm(t *testing.T, path string, form url.Values) (int,
 	return rs.StatusCode, rs.Header, string(body)
 }
 
+// Do sends req to the server, filling in its scheme and host.
+func (ts *testServer) Do(t *testing.T, req *http.Request) (int, http.Header, string) {
+	t.Helper()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	req.Host = u.Host
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
 var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)">`)
 
 func extractCSRFToken(t *testing.T, body string) string {
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+const (
+	// viewStatsDays is how many days the stats page shows daily views for.
+	viewStatsDays = 30
+	// viewStatsReferrers is how many of the top referrers it shows.
+	viewStatsReferrers = 10
+	maxReferrerLength  = 255
+)
+
+// viewCounter buffers snippet views in memory so reading a snippet doesn't
+// write to the database. The views are recorded in batches by flushViews.
+type viewCounter struct {
+	mu        sync.Mutex
+	views     map[viewKey]int
+	visitors  map[visitorKey]bool
+	referrers map[referrerKey]int
+	// salt keys the visitor hashes of saltDay. It's replaced every day and
+	// never stored, so the IP addresses can't be found back from the hashes.
+	salt    []byte
+	saltDay time.Time
+	now     func() time.Time
+}
+
+type viewKey struct {
+	snippetID int
+	day       time.Time
+}
+
+type visitorKey struct {
+	viewKey
+	hash string
+}
+
+type referrerKey struct {
+	snippetID int
+	referrer  string
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{
+		views:     map[viewKey]int{},
+		visitors:  map[visitorKey]bool{},
+		referrers: map[referrerKey]int{},
+		now:       time.Now,
+	}
+}
+
+// Add counts a view of a snippet by the client with the given IP address,
+// coming from the given referrer host, which is empty for direct visits.
+func (c *viewCounter) Add(snippetID int, ip string, referrer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := viewKey{snippetID: snippetID, day: c.now().UTC().Truncate(24 * time.Hour)}
+	c.views[key]++
+	if c.rotateSalt(key.day) {
+		c.visitors[visitorKey{viewKey: key, hash: visitorHash(c.salt, ip)}] = true
+	}
+	if referrer != "" {
+		c.referrers[referrerKey{snippetID: snippetID, referrer: referrer}]++
+	}
+}
+
+// Take returns the views counted so far and empties the counter. It returns
+// nil when there are none.
+func (c *viewCounter) Take() *models.ViewBatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.views) == 0 {
+		return nil
+	}
+
+	var b models.ViewBatch
+	for k, n := range c.views {
+		b.Views = append(b.Views, models.ViewCount{SnippetID: k.snippetID, Day: k.day, Views: n})
+	}
+	for k := range c.visitors {
+		b.Visitors = append(b.Visitors, models.Visitor{SnippetID: k.snippetID, Day: k.day, Hash: k.hash})
+	}
+	for k, n := range c.referrers {
+		b.Referrers = append(b.Referrers, models.ReferrerCount{SnippetID: k.snippetID, Referrer: k.referrer, Views: n})
+	}
+
+	c.views = map[viewKey]int{}
+	c.visitors = map[visitorKey]bool{}
+	c.referrers = map[referrerKey]int{}
+	return &b
+}
+
+// rotateSalt makes sure the salt is the one of day, and reports whether
+// there's one. Visitors aren't counted when no salt could be made. Visitors
+// of a day the app is restarted on are counted again with the new salt.
+func (c *viewCounter) rotateSalt(day time.Time) bool {
+	if c.salt != nil && c.saltDay.Equal(day) {
+		return true
+	}
+
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	if err != nil {
+		c.salt = nil
+		return false
+	}
+
+	c.salt, c.saltDay = salt, day
+	return true
+}
+
+// visitorHash identifies a client without keeping its IP address, as the
+// HMAC of the address keyed with the salt of the day.
+func visitorHash(salt []byte, ip string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// referrerHost returns the host of the page which linked to a snippet, or an
+// empty string when the request didn't come from another site.
+func referrerHost(referer string, host string) string {
+	u, err := url.Parse(referer)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	ref := strings.ToLower(u.Host)
+	if ref == strings.ToLower(host) {
+		return ""
+	}
+
+	if len(ref) > maxReferrerLength {
+		// Cut at the start of a character so a multibyte one isn't split.
+		n := maxReferrerLength
+		for n > 0 && !utf8.RuneStart(ref[n]) {
+			n--
+		}
+		ref = ref[:n]
+	}
+	return ref
+}
+
+// flushViewsEvery records the buffered views every interval until ctx is
+// done, then records what's left one last time.
+func (app *Application) flushViewsEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			app.flushViews(flushCtx)
+			return
+		case <-ticker.C:
+			app.flushViews(ctx)
+		}
+	}
+}
+
+// flushViews records the buffered views and returns how many there were.
+// Views which fail to be recorded are dropped rather than kept, so the
+// buffer doesn't grow while the database is down.
+func (app *Application) flushViews(ctx context.Context) int {
+	b := app.viewCounter.Take()
+	if b == nil {
+		return 0
+	}
+
+	total := 0
+	for _, v := range b.Views {
+		total += v.Views
+	}
+
+	err := app.views.Record(ctx, b)
+	if err != nil {
+		app.errLog.Printf("dropped %d snippet views: %s", total, err)
+		return 0
+	}
+
+	return total
+}
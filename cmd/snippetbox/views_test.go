@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+func TestViewCounter(t *testing.T) {
+	now := time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC)
+	c := newViewCounter()
+	c.now = func() time.Time { return now }
+
+	assert.Equal(t, c.Take() == nil, true)
+
+	c.Add(1, "10.0.0.1", "")
+	c.Add(1, "10.0.0.1", "news.ycombinator.com")
+	c.Add(1, "10.0.0.2", "news.ycombinator.com")
+	c.Add(2, "10.0.0.1", "")
+	now = now.Add(6 * time.Hour)
+	c.Add(1, "10.0.0.1", "")
+
+	b := c.Take()
+	views := map[string]int{}
+	for _, v := range b.Views {
+		views[fmt.Sprintf("%s/%d", v.Day.Format("2006-01-02"), v.SnippetID)] = v.Views
+	}
+	assert.Equal(t, len(views), 3)
+	assert.Equal(t, views["2023-05-10/1"], 3)
+	assert.Equal(t, views["2023-05-10/2"], 1)
+	assert.Equal(t, views["2023-05-11/1"], 1)
+	assert.Equal(t, len(b.Visitors), 4)
+	assert.Equal(t, len(b.Referrers), 1)
+	assert.Equal(t, b.Referrers[0], models.ReferrerCount{SnippetID: 1, Referrer: "news.ycombinator.com", Views: 2})
+
+	assert.Equal(t, c.Take() == nil, true)
+}
+
+func TestVisitorHash(t *testing.T) {
+	salt := []byte("salt of the day")
+
+	assert.Equal(t, visitorHash(salt, "10.0.0.1"), visitorHash(salt, "10.0.0.1"))
+	assert.Equal(t, visitorHash(salt, "10.0.0.1") == visitorHash(salt, "10.0.0.2"), false)
+	assert.Equal(t, visitorHash(salt, "10.0.0.1") == visitorHash([]byte("salt of another day"), "10.0.0.1"), false)
+	assert.Equal(t, len(visitorHash(salt, "10.0.0.1")), 64)
+}
+
+func TestViewCounterRotatesSalt(t *testing.T) {
+	now := time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC)
+	c := newViewCounter()
+	c.now = func() time.Time { return now }
+
+	c.Add(1, "10.0.0.1", "")
+	first := c.Take().Visitors[0].Hash
+	c.Add(1, "10.0.0.1", "")
+	assert.Equal(t, c.Take().Visitors[0].Hash, first)
+
+	now = now.Add(6 * time.Hour)
+	c.Add(1, "10.0.0.1", "")
+	assert.Equal(t, c.Take().Visitors[0].Hash == first, false)
+}
+
+func TestReferrerHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		referer string
+		want    string
+	}{
+		{
+			name: "Direct",
+			want: "",
+		},
+		{
+			name:    "Other site",
+			referer: "https://News.YCombinator.com/item?id=1",
+			want:    "news.ycombinator.com",
+		},
+		{
+			name:    "Same site",
+			referer: "https://snippetbox.dev/snippet/search?q=pond",
+			want:    "",
+		},
+		{
+			name:    "Long host",
+			referer: "https://" + strings.Repeat("a", 254) + "é.example.com/",
+			want:    strings.Repeat("a", 254),
+		},
+		{
+			name:    "Not a URL",
+			referer: "::nonsense",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, referrerHost(tt.referer, "snippetbox.dev"), tt.want)
+		})
+	}
+}
+
+// recordedViews remembers the batches of views it's asked to record.
+type recordedViews struct {
+	batches []*models.ViewBatch
+	err     error
+}
+
+func (v *recordedViews) Record(ctx context.Context, b *models.ViewBatch) error {
+	if v.err != nil {
+		return v.err
+	}
+
+	v.batches = append(v.batches, b)
+	return nil
+}
+
+func (v *recordedViews) Stats(snippetID int, since time.Time, referrers int) (*models.ViewStats, error) {
+	return &models.ViewStats{}, nil
+}
+
+func TestFlushViews(t *testing.T) {
+	app := newTestApplication(t)
+	views := &recordedViews{}
+	app.views = views
+
+	assert.Equal(t, app.flushViews(context.Background()), 0)
+	assert.Equal(t, len(views.batches), 0)
+
+	app.viewCounter.Add(1, "10.0.0.1", "")
+	app.viewCounter.Add(1, "10.0.0.2", "")
+	assert.Equal(t, app.flushViews(context.Background()), 2)
+	assert.Equal(t, len(views.batches), 1)
+
+	views.err = errors.New("connection refused")
+	app.viewCounter.Add(1, "10.0.0.1", "")
+	assert.Equal(t, app.flushViews(context.Background()), 0)
+	assert.Equal(t, app.viewCounter.Take() == nil, true)
+}
+
+func TestFlushViewsEveryFlushesOnStop(t *testing.T) {
+	app := newTestApplication(t)
+	views := &recordedViews{}
+	app.views = views
+	app.viewCounter.Add(1, "10.0.0.1", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.flushViewsEvery(ctx, time.Hour)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("view flusher didn't stop after its context was cancelled")
+	}
+	assert.Equal(t, len(views.batches), 1)
+}
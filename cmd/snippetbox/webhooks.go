@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+const (
+	// webhookBatchSize is how many deliveries a worker claims at once.
+	webhookBatchSize = 20
+	// webhookLease is how long claimed deliveries are held back from other
+	// workers. It must outlast sending a whole batch.
+	webhookLease   = 5 * time.Minute
+	webhookTimeout = 10 * time.Second
+	// webhookMaxAttempts is how many times a delivery is tried before it's
+	// given up on.
+	webhookMaxAttempts = 8
+	webhookMinBackoff  = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// webhookLogSize is how many entries of the delivery log the webhooks
+	// page shows.
+	webhookLogSize = 20
+	// maxWebhookErrorLength is how much of a failed request's error is
+	// kept in the delivery log.
+	maxWebhookErrorLength = 255
+)
+
+// webhookPayload is the JSON body sent to webhooks.
+type webhookPayload struct {
+	DeliveryID int            `json:"delivery_id"`
+	Event      string         `json:"event"`
+	Time       time.Time      `json:"time"`
+	Snippet    webhookSnippet `json:"snippet"`
+}
+
+type webhookSnippet struct {
+	Slug       string     `json:"slug"`
+	Title      string     `json:"title"`
+	URL        string     `json:"url"`
+	Visibility string     `json:"visibility"`
+	Expires    *time.Time `json:"expires"`
+}
+
+// errInternalAddress is the error of deliveries to webhooks which resolve to
+// an address the server mustn't be made to reach.
+var errInternalAddress = errors.New("webhooks can't be sent to internal addresses")
+
+// nonPublicPrefixes are the ranges which aren't reachable on the internet
+// besides the loopback, private, link-local, multicast and unspecified ones.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddr reports whether webhooks may be sent to addr, which is the
+// case for addresses on the internet but not for those of the server's own
+// host or network, such as cloud metadata endpoints.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isInternalURL reports whether a webhook URL names localhost or an IP
+// address isPublicAddr refuses. Host names are only checked once they're
+// resolved, when dialing.
+func isInternalURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && !isPublicAddr(addr)
+}
+
+// newWebhookClient returns the client webhooks are sent with, which only
+// connects to the addresses allowed reports true for. The address is checked
+// once resolved, right before connecting, so a host name can't be made to
+// resolve to another address after it's checked. Redirects aren't followed,
+// so they count as failed deliveries.
+func newWebhookClient(allowed func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowed(addrPort.Addr()) {
+				return errInternalAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		// No proxy is used, as it would connect to webhooks out of reach of
+		// the dialer.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// deliverWebhooksEvery sends the queued webhook deliveries right away, then
+// every interval until ctx is done. Deliveries left are sent once the app is
+// started again.
+func (app *Application) deliverWebhooksEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	app.deliverWebhooks(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.deliverWebhooks(ctx)
+		}
+	}
+}
+
+// deliverWebhooks sends the deliveries which are due, in batches until none
+// are left, and returns how many succeeded.
+func (app *Application) deliverWebhooks(ctx context.Context) int {
+	delivered := 0
+	for {
+		deliveries, err := app.webhooks.Claim(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			if ctx.Err() == nil {
+				app.errLog.Print(err)
+			}
+			return delivered
+		}
+
+		for i := range deliveries {
+			d := &deliveries[i]
+			a := app.deliverWebhook(ctx, d)
+			if ctx.Err() != nil {
+				// The delivery is tried again once its lease is over.
+				return delivered
+			}
+
+			var retryAt time.Time
+			if !a.Succeeded() && a.Attempt < webhookMaxAttempts {
+				retryAt = time.Now().Add(webhookBackoff(a.Attempt))
+			}
+			err := app.webhooks.RecordAttempt(ctx, a, retryAt)
+			if err != nil {
+				app.errLog.Print(err)
+			}
+			if a.Succeeded() {
+				delivered++
+			}
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return delivered
+		}
+	}
+}
+
+// deliverWebhook sends a delivery to its webhook and returns the attempt.
+func (app *Application) deliverWebhook(ctx context.Context, d *models.Delivery) *models.DeliveryAttempt {
+	a := &models.DeliveryAttempt{
+		DeliveryID: d.ID,
+		WebhookID:  d.WebhookID,
+		URL:        d.URL,
+		Event:      d.Event,
+		Attempt:    d.Attempts + 1,
+	}
+
+	body, err := json.Marshal(app.newWebhookPayload(d))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = truncateError(err.Error())
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Snippetbox-Webhook")
+	req.Header.Set("X-Snippetbox-Event", d.Event)
+	req.Header.Set("X-Snippetbox-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Snippetbox-Signature", signWebhook(d.Secret, body))
+
+	resp, err := app.webhookClient.Do(req)
+	if errors.Is(err, errInternalAddress) {
+		// The address the host resolved to is left out of the log.
+		a.Error = errInternalAddress.Error()
+		return a
+	}
+	if err != nil {
+		a.Error = truncateError(err.Error())
+		return a
+	}
+	defer resp.Body.Close()
+	// Draining a little of the body lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	a.StatusCode = resp.StatusCode
+	return a
+}
+
+func (app *Application) newWebhookPayload(d *models.Delivery) webhookPayload {
+	s := webhookSnippet{
+		Slug:       d.Snippet.Slug,
+		Title:      d.Snippet.Title,
+		URL:        app.publicURL + "/s/" + d.Snippet.Slug,
+		Visibility: d.Snippet.Visibility,
+	}
+	if !d.Snippet.Expires.IsZero() {
+		expires := d.Snippet.Expires
+		s.Expires = &expires
+	}
+
+	return webhookPayload{DeliveryID: d.ID, Event: d.Event, Time: d.Created, Snippet: s}
+}
+
+// signWebhook returns the signature of a webhook body, the hex HMAC-SHA256
+// of the body keyed with the webhook secret.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns how long to wait before retrying a delivery which
+// failed for the given attempt, doubling from webhookMinBackoff up to
+// webhookMaxBackoff.
+func webhookBackoff(attempt int) time.Duration {
+	backoff := webhookMinBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+
+	return backoff
+}
+
+func truncateError(message string) string {
+	if len(message) > maxWebhookErrorLength {
+		return message[:maxWebhookErrorLength]
+	}
+
+	return message
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+	"github.com/huytran2000-hcmus/snippetbox/internal/mock"
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+// queuedWebhooks is a delivery queue in memory, recording what's done to it.
+type queuedWebhooks struct {
+	mock.StubWebhooks
+	queue    []models.Delivery
+	claims   int
+	attempts []models.DeliveryAttempt
+	retries  []time.Time
+}
+
+func (q *queuedWebhooks) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.Delivery, error) {
+	q.claims++
+	n := len(q.queue)
+	if n > limit {
+		n = limit
+	}
+	claimed := q.queue[:n]
+	q.queue = q.queue[n:]
+	return claimed, nil
+}
+
+func (q *queuedWebhooks) RecordAttempt(ctx context.Context, a *models.DeliveryAttempt, retryAt time.Time) error {
+	q.attempts = append(q.attempts, *a)
+	q.retries = append(q.retries, retryAt)
+	return nil
+}
+
+// webhookReceiver records the payloads it's sent, and answers with the
+// status code given by their path.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	payloads []webhookPayload
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte("a very secret secret"))
+	mac.Write(body)
+	if r.Header.Get("X-Snippetbox-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	var p webhookPayload
+	err = json.Unmarshal(body, &p)
+	if err != nil || r.Header.Get("Content-Type") != "application/json" ||
+		r.Header.Get("X-Snippetbox-Event") != p.Event ||
+		r.Header.Get("X-Snippetbox-Delivery") != strconv.Itoa(p.DeliveryID) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+		return
+	}
+
+	rc.mu.Lock()
+	rc.payloads = append(rc.payloads, p)
+	rc.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/ok":
+		w.WriteHeader(http.StatusNoContent)
+	case "/redirect":
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func TestDeliverWebhooks(t *testing.T) {
+	receiver := &webhookReceiver{}
+	ts := httptest.NewServer(receiver)
+	defer ts.Close()
+
+	closed := httptest.NewServer(receiver)
+	closed.Close()
+
+	expires := time.Date(2023, time.May, 17, 20, 0, 0, 0, time.UTC)
+	created := time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC)
+	delivery := func(id int, url string, attempts int) models.Delivery {
+		return models.Delivery{
+			ID:        id,
+			WebhookID: 1,
+			URL:       url,
+			Secret:    "a very secret secret",
+			Event:     models.EventSnippetCreated,
+			Snippet:   models.Snippet{ID: 1, Slug: "pondXy12Ab", Title: "An old silent pond", Visibility: models.VisibilityPublic, Expires: expires},
+			Attempts:  attempts,
+			Created:   created,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		delivery       models.Delivery
+		wantStatusCode int
+		wantError      bool
+		wantRetryIn    time.Duration
+	}{
+		{
+			name:           "Accepted",
+			delivery:       delivery(1, ts.URL+"/ok", 0),
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "Failed",
+			delivery:       delivery(2, ts.URL+"/fail", 0),
+			wantStatusCode: http.StatusInternalServerError,
+			wantRetryIn:    webhookMinBackoff,
+		},
+		{
+			name:           "Failed for the last time",
+			delivery:       delivery(3, ts.URL+"/fail", webhookMaxAttempts-1),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "Redirected",
+			delivery:       delivery(4, ts.URL+"/redirect", 1),
+			wantStatusCode: http.StatusFound,
+			wantRetryIn:    2 * webhookMinBackoff,
+		},
+		{
+			name:        "Unreachable",
+			delivery:    delivery(5, closed.URL+"/ok", 2),
+			wantError:   true,
+			wantRetryIn: 4 * webhookMinBackoff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			q := &queuedWebhooks{queue: []models.Delivery{tt.delivery}}
+			app.webhooks = q
+
+			before := time.Now()
+			delivered := app.deliverWebhooks(context.Background())
+
+			if tt.wantStatusCode == http.StatusNoContent {
+				assert.Equal(t, delivered, 1)
+			} else {
+				assert.Equal(t, delivered, 0)
+			}
+			assert.Equal(t, len(q.attempts), 1)
+			a := q.attempts[0]
+			assert.Equal(t, a.DeliveryID, tt.delivery.ID)
+			assert.Equal(t, a.Attempt, tt.delivery.Attempts+1)
+			assert.Equal(t, a.StatusCode, tt.wantStatusCode)
+			assert.Equal(t, a.Error != "", tt.wantError)
+
+			retryAt := q.retries[0]
+			if tt.wantRetryIn == 0 {
+				assert.Equal(t, retryAt.IsZero(), true)
+			} else {
+				assert.Equal(t, !retryAt.Before(before.Add(tt.wantRetryIn)), true)
+				assert.Equal(t, !retryAt.After(time.Now().Add(tt.wantRetryIn)), true)
+			}
+		})
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	// The unreachable webhook got nothing and the redirect wasn't followed.
+	assert.Equal(t, len(receiver.payloads), 4)
+	p := receiver.payloads[0]
+	assert.Equal(t, p.DeliveryID, 1)
+	assert.Equal(t, p.Event, models.EventSnippetCreated)
+	assert.Equal(t, p.Time, created)
+	assert.Equal(t, p.Snippet.Slug, "pondXy12Ab")
+	assert.Equal(t, p.Snippet.URL, "https://snippetbox.example.com/s/pondXy12Ab")
+	assert.Equal(t, p.Snippet.Expires.Equal(expires), true)
+}
+
+func TestDeliverWebhooksInternalAddress(t *testing.T) {
+	receiver := &webhookReceiver{}
+	ts := httptest.NewServer(receiver)
+	defer ts.Close()
+
+	// The webhook is refused when dialing, whatever was checked when it was
+	// added.
+	app := newTestApplication(t)
+	app.webhookClient = newWebhookClient(isPublicAddr)
+	q := &queuedWebhooks{queue: []models.Delivery{{ID: 1, URL: ts.URL + "/ok", Secret: "a very secret secret", Event: models.EventSnippetCreated}}}
+	app.webhooks = q
+
+	assert.Equal(t, app.deliverWebhooks(context.Background()), 0)
+	assert.Equal(t, len(q.attempts), 1)
+	assert.Equal(t, q.attempts[0].StatusCode, 0)
+	assert.Equal(t, q.attempts[0].Error, "webhooks can't be sent to internal addresses")
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	assert.Equal(t, len(receiver.payloads), 0)
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1", want: false},
+		{addr: "::1", want: false},
+		{addr: "10.1.2.3", want: false},
+		{addr: "172.16.0.1", want: false},
+		{addr: "192.168.1.1", want: false},
+		{addr: "169.254.169.254", want: false},
+		{addr: "fe80::1", want: false},
+		{addr: "fd00::1", want: false},
+		{addr: "0.0.0.0", want: false},
+		{addr: "::", want: false},
+		{addr: "100.100.100.200", want: false},
+		{addr: "::ffff:127.0.0.1", want: false},
+		{addr: "224.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, isPublicAddr(netip.MustParseAddr(tt.addr)), tt.want)
+		})
+	}
+}
+
+func TestDeliverWebhooksBatches(t *testing.T) {
+	ts := httptest.NewServer(&webhookReceiver{})
+	defer ts.Close()
+
+	q := &queuedWebhooks{}
+	for i := 1; i <= webhookBatchSize+5; i++ {
+		q.queue = append(q.queue, models.Delivery{
+			ID:      i,
+			URL:     ts.URL + "/ok",
+			Secret:  "a very secret secret",
+			Event:   models.EventSnippetExpired,
+			Snippet: models.Snippet{ID: i, Slug: "expired" + strconv.Itoa(i)},
+		})
+	}
+
+	app := newTestApplication(t)
+	app.webhooks = q
+
+	assert.Equal(t, app.deliverWebhooks(context.Background()), webhookBatchSize+5)
+	assert.Equal(t, q.claims, 2)
+	assert.Equal(t, len(q.queue), 0)
+}
+
+// signalledWebhooks signals each claim of deliveries.
+type signalledWebhooks struct {
+	mock.StubWebhooks
+	claimed chan struct{}
+}
+
+func (s *signalledWebhooks) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.Delivery, error) {
+	select {
+	case s.claimed <- struct{}{}:
+	default:
+	}
+	return nil, nil
+}
+
+func TestDeliverWebhooksEveryStartsRightAway(t *testing.T) {
+	app := newTestApplication(t)
+	webhooks := &signalledWebhooks{claimed: make(chan struct{}, 1)}
+	app.webhooks = webhooks
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.deliverWebhooksEvery(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-webhooks.claimed:
+	case <-time.After(time.Second):
+		t.Error("the queued deliveries weren't sent before the first tick")
+	}
+	cancel()
+	<-done
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 30 * time.Second},
+		{attempt: 2, want: time.Minute},
+		{attempt: 3, want: 2 * time.Minute},
+		{attempt: 10, want: 256 * time.Minute},
+		{attempt: 11, want: 6 * time.Hour},
+		{attempt: 100, want: 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			assert.Equal(t, webhookBackoff(tt.attempt), tt.want)
+		})
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	got := signWebhook("key", []byte("The quick brown fox jumps over the lazy dog"))
+	assert.Equal(t, got, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8")
+}
@@ -9,7 +9,21 @@ require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/justinas/alice v1.2.0
 	github.com/lib/pq v1.10.7
-	golang.org/x/crypto v0.8.0
+	golang.org/x/crypto v0.11.0
 )
 
-require github.com/justinas/nosurf v1.1.1
+require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/justinas/nosurf v1.1.1
+	github.com/microcosm-cc/bluemonday v1.0.25
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/yuin/goldmark v1.7.4
+	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	github.com/gorilla/css v1.0.0 // indirect
+	golang.org/x/net v0.12.0 // indirect
+)
@@ -20,3 +20,11 @@ func StringContains(t *testing.T, s string, substr string) {
 		t.Errorf("%q didn't contain %q", s, substr)
 	}
 }
+
+func StringNotContains(t *testing.T, s string, substr string) {
+	t.Helper()
+
+	if strings.Contains(s, substr) {
+		t.Errorf("%q contained %q", s, substr)
+	}
+}
@@ -0,0 +1,106 @@
+package highlight
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Style is the chroma style ui/static/css/chroma.css was generated from.
+const Style = "github"
+
+// PlainText is the language of snippets without syntax highlighting.
+const PlainText = "plaintext"
+
+type Language struct {
+	Name      string
+	Label     string
+	Extension string
+}
+
+// Languages are the languages a snippet can be written in, in the order they
+// are offered to users.
+var Languages = []Language{
+	{Name: PlainText, Label: "Plain text", Extension: "txt"},
+	{Name: "bash", Label: "Shell", Extension: "sh"},
+	{Name: "c", Label: "C", Extension: "c"},
+	{Name: "css", Label: "CSS", Extension: "css"},
+	{Name: "docker", Label: "Dockerfile", Extension: "dockerfile"},
+	{Name: "go", Label: "Go", Extension: "go"},
+	{Name: "html", Label: "HTML", Extension: "html"},
+	{Name: "java", Label: "Java", Extension: "java"},
+	{Name: "javascript", Label: "JavaScript", Extension: "js"},
+	{Name: "json", Label: "JSON", Extension: "json"},
+	{Name: "markdown", Label: "Markdown", Extension: "md"},
+	{Name: "python", Label: "Python", Extension: "py"},
+	{Name: "rust", Label: "Rust", Extension: "rs"},
+	{Name: "sql", Label: "SQL", Extension: "sql"},
+	{Name: "toml", Label: "TOML", Extension: "toml"},
+	{Name: "typescript", Label: "TypeScript", Extension: "ts"},
+	{Name: "yaml", Label: "YAML", Extension: "yaml"},
+}
+
+// Names returns the names of all supported languages.
+func Names() []string {
+	names := make([]string, len(Languages))
+	for i, l := range Languages {
+		names[i] = l.Name
+	}
+
+	return names
+}
+
+// Lookup returns the supported language with the given name.
+func Lookup(name string) (Language, bool) {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l, true
+		}
+	}
+
+	return Language{}, false
+}
+
+// LinePrefix prefixes the id of every highlighted line, so line 12 can be
+// linked to with #L12.
+const LinePrefix = "L"
+
+// HTML highlights content written in language. The result only uses CSS
+// classes, never inline styles, so it's allowed by a strict
+// Content-Security-Policy. Unknown languages are rendered as plain text.
+func HTML(content string, language string) (string, error) {
+	return HTMLWithPrefix(content, language, LinePrefix)
+}
+
+// HTMLWithPrefix is like HTML but prefixes the id of every line with
+// linePrefix, so several highlighted blocks can share a page.
+func HTMLWithPrefix(content string, language string, linePrefix string) (string, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", fmt.Errorf("highlight: tokenise %s content: %s", language, err)
+	}
+
+	formatter := html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.WithLinkableLineNumbers(true, linePrefix),
+	)
+
+	var b strings.Builder
+	err = formatter.Format(&b, styles.Get(Style), iterator)
+	if err != nil {
+		return "", fmt.Errorf("highlight: format %s content: %s", language, err)
+	}
+
+	return b.String(), nil
+}
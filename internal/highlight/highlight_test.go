@@ -0,0 +1,63 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		want     string
+	}{
+		{
+			name:     "Go",
+			content:  "package main\n\nfunc main() {}\n",
+			language: "go",
+			want:     `<span class="kn">package</span>`,
+		},
+		{
+			name:     "Line anchors",
+			content:  "SELECT 1;\nSELECT 2;\n",
+			language: "sql",
+			want:     `<span class="ln" id="L2"><a class="lnlinks" href="#L2">2</a></span>`,
+		},
+		{
+			name:     "Escaped content",
+			content:  "<script>alert(1)</script>",
+			language: PlainText,
+			want:     "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name:     "Unknown language",
+			content:  "foo",
+			language: "klingon",
+			want:     "foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML(tt.content, tt.language)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.StringContains(t, got, tt.want)
+			assert.Equal(t, strings.Contains(got, "style="), false)
+		})
+	}
+}
+
+func TestHTMLWithPrefix(t *testing.T) {
+	got, err := HTMLWithPrefix("a\nb\n", PlainText, "F2L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.StringContains(t, got, `<span class="ln" id="F2L2"><a class="lnlinks" href="#F2L2">2</a></span>`)
+}
@@ -0,0 +1,89 @@
+// Package markdown renders Markdown snippets to sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/huytran2000-hcmus/snippetbox/internal/highlight"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Language is the snippet language rendered as Markdown.
+const Language = "markdown"
+
+var converter = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle(highlight.Style),
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		),
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(util.Prioritized(headingAnchors{}, 1000)),
+	),
+)
+
+// policy only lets through the elements Markdown produces. Raw HTML is
+// already dropped by goldmark, the policy is there in case anything slips
+// through it.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(false)
+	p.RequireNoFollowOnFullyQualifiedLinks(true)
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z0-9 -]+$`)).OnElements("pre", "code", "span", "a")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[a-z0-9_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	return p
+}
+
+// HTML renders Markdown source to HTML which is safe to embed in a page as
+// is. It supports GitHub flavoured Markdown, including tables, and
+// highlights fenced code blocks with the same CSS classes as snippets.
+// Headings get an id and a link to themselves.
+func HTML(source string) (string, error) {
+	var buf bytes.Buffer
+	err := converter.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", fmt.Errorf("markdown: convert: %s", err)
+	}
+
+	return policy.Sanitize(buf.String()), nil
+}
+
+// headingAnchors prepends to every heading a link to itself.
+type headingAnchors struct{}
+
+func (headingAnchors) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+
+		link := ast.NewLink()
+		link.Destination = append([]byte("#"), id.([]byte)...)
+		link.SetAttributeString("class", []byte("anchor"))
+		link.AppendChild(link, ast.NewString([]byte("#")))
+		heading.InsertBefore(heading, heading.FirstChild(), link)
+
+		return ast.WalkSkipChildren, nil
+	})
+}
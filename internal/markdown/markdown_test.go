@@ -0,0 +1,66 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		notWant string
+	}{
+		{
+			name:   "Heading anchor",
+			source: "# Team notes\n",
+			want:   `<h1 id="team-notes"><a href="#team-notes" class="anchor">#</a>Team notes</h1>`,
+		},
+		{
+			name:   "Table",
+			source: "| a | b |\n|---|---|\n| 1 | 2 |\n",
+			want:   "<td>1</td>",
+		},
+		{
+			name:   "Fenced code block",
+			source: "```go\npackage main\n```\n",
+			want:   `<span class="kn">package</span>`,
+		},
+		{
+			name:   "External link",
+			source: "[Go](https://go.dev)\n",
+			want:   `<a href="https://go.dev" rel="nofollow">Go</a>`,
+		},
+		{
+			name:    "Raw HTML",
+			source:  "<script>alert(1)</script>\n",
+			notWant: "<script>",
+		},
+		{
+			name:    "Event handler",
+			source:  "<img src=x onerror=alert(1)>\n",
+			notWant: "onerror",
+		},
+		{
+			name:    "JavaScript link",
+			source:  "[click](javascript:alert(1))\n",
+			notWant: "javascript:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.StringContains(t, got, tt.want)
+			if tt.notWant != "" {
+				assert.StringNotContains(t, got, tt.notWant)
+			}
+		})
+	}
+}
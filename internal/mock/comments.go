@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+var mockComments = []models.Comment{
+	{
+		ID:        1,
+		SnippetID: 1,
+		UserID:    2,
+		UserName:  "bob",
+		Body:      "Lovely, but L1 could be <b>shorter</b>.",
+		Created:   time.Now(),
+	},
+	{
+		ID:        2,
+		SnippetID: 1,
+		ParentID:  1,
+		UserID:    1,
+		UserName:  "alice",
+		Body:      "It's a classic!",
+		Created:   time.Now(),
+	},
+}
+
+type StubComments struct{}
+
+func (s *StubComments) Insert(c *models.Comment) error {
+	if c.ParentID != 0 && c.ParentID != 1 && c.ParentID != 2 {
+		return models.ErrNoRecord
+	}
+
+	c.ID = 3
+	c.Created = time.Now()
+	return nil
+}
+
+func (s *StubComments) Get(id int) (*models.Comment, error) {
+	for _, c := range mockComments {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (s *StubComments) BySnippet(snippetID int) ([]models.Comment, error) {
+	if snippetID == 1 {
+		return mockComments, nil
+	}
+
+	return nil, nil
+}
+
+func (s *StubComments) Delete(id int) error {
+	switch id {
+	case 1, 2:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
@@ -1,34 +1,306 @@
 package mock
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"github.com/huytran2000-hcmus/snippetbox/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      0,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	Slug:       "pondXy12Ab",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "plaintext",
+	Files:      []models.SnippetFile{{Name: "pond.txt", Language: "plaintext", Content: "An old silent pond..."}},
+	Created:    time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC),
+	Updated:    time.Date(2023, time.May, 11, 8, 30, 0, 0, time.UTC),
+	Expires:    time.Now(),
+	UserID:     1,
+	UserName:   "alice",
+	Visibility: models.VisibilityPublic,
+	Tags:       []string{"haiku", "nature"},
+	ForkCount:  1,
+	StarCount:  2,
 }
 
-type StubSnippets struct{}
+var mockPrivateSnippet = &models.Snippet{
+	ID:         3,
+	Slug:       "forest34Cd",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest...",
+	Language:   "plaintext",
+	Files:      []models.SnippetFile{{Name: "forest.txt", Language: "plaintext", Content: "Over the wintry forest..."}},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	UserName:   "alice",
+	Visibility: models.VisibilityPrivate,
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:               4,
+	Slug:             "burnMe78Gh",
+	Title:            "First autumn morning",
+	Content:          "First autumn morning...",
+	Language:         "plaintext",
+	Files:            []models.SnippetFile{{Name: "autumn.txt", Language: "plaintext", Content: "First autumn morning..."}},
+	Created:          time.Now(),
+	Expires:          time.Now(),
+	BurnAfterReading: true,
+	UserID:           1,
+	UserName:         "alice",
+	Visibility:       models.VisibilityUnlisted,
+}
+
+var mockProtectedSnippet = &models.Snippet{
+	ID:         5,
+	Slug:       "lockedZ9Kq",
+	Title:      "A secret config",
+	Content:    "password = hunter2",
+	Language:   "plaintext",
+	Files:      []models.SnippetFile{{Name: "config.txt", Language: "plaintext", Content: "password = hunter2"}},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Protected:  true,
+	UserID:     1,
+	UserName:   "alice",
+	Visibility: models.VisibilityUnlisted,
+}
+
+var mockBundleSnippet = &models.Snippet{
+	ID:       6,
+	Slug:     "bundleQ7Rt",
+	Title:    "Hello server",
+	Content:  "package main",
+	Language: "go",
+	Files: []models.SnippetFile{
+		{Name: "main.go", Language: "go", Content: "package main"},
+		{Name: "go.mod", Language: "plaintext", Content: "module hello"},
+	},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	UserName:   "alice",
+	Visibility: models.VisibilityPublic,
+}
+
+var mockMarkdownSnippet = &models.Snippet{
+	ID:       7,
+	Slug:     "notesM3Dn",
+	Title:    "Team notes",
+	Content:  "# Team notes\n\n<script>alert(1)</script>\n",
+	Language: "markdown",
+	Files: []models.SnippetFile{
+		{Name: "notes.md", Language: "markdown", Content: "# Team notes\n\n<script>alert(1)</script>\n"},
+	},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	UserName:   "alice",
+	Visibility: models.VisibilityPublic,
+}
+
+var mockRevisions = []models.Revision{
+	{
+		SnippetID: 1,
+		Number:    1,
+		Title:     "An old pond",
+		Content:   "An old pond...",
+		Language:  "plaintext",
+		Files: []models.SnippetFile{
+			{Name: "pond.txt", Language: "plaintext", Content: "An old pond..."},
+			{Name: "notes.txt", Language: "plaintext", Content: "By Matsuo Basho"},
+		},
+		EditorID:   1,
+		EditorName: "alice",
+		Created:    time.Now(),
+	},
+	{
+		SnippetID:  1,
+		Number:     2,
+		Title:      mockSnippet.Title,
+		Content:    mockSnippet.Content,
+		Language:   mockSnippet.Language,
+		Files:      mockSnippet.Files,
+		EditorID:   1,
+		EditorName: "alice",
+		Created:    time.Now(),
+	},
+}
+
+type StubSnippets struct {
+	// Inserted is the last snippet passed to Insert.
+	Inserted *models.Snippet
+}
 
-func (s *StubSnippets) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (s *StubSnippets) Insert(snippet *models.Snippet) error {
+	s.Inserted = snippet
+	snippet.ID = 2
+	snippet.Slug = "newSnip56E"
+	snippet.Created = time.Now()
+	return nil
 }
 
 func (s *StubSnippets) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockPrivateSnippet, nil
+	case 4:
+		return mockBurnSnippet, nil
+	case 5:
+		return mockProtectedSnippet, nil
+	case 6:
+		return mockBundleSnippet, nil
+	case 7:
+		return mockMarkdownSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (s *StubSnippets) GetBySlug(slug string) (*models.Snippet, error) {
+	switch slug {
+	case mockSnippet.Slug:
+		return mockSnippet, nil
+	case mockPrivateSnippet.Slug:
+		return mockPrivateSnippet, nil
+	case mockBurnSnippet.Slug:
+		return mockBurnSnippet, nil
+	case mockProtectedSnippet.Slug:
+		return mockProtectedSnippet, nil
+	case mockBundleSnippet.Slug:
+		return mockBundleSnippet, nil
+	case mockMarkdownSnippet.Slug:
+		return mockMarkdownSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (s *StubSnippets) Latest() ([]models.Snippet, error) {
+func (s *StubSnippets) List(page int, pageSize int) ([]models.Snippet, int, error) {
+	if page > 1 {
+		return nil, 1, nil
+	}
+
+	return []models.Snippet{*mockSnippet}, 1, nil
+}
+
+func (s *StubSnippets) ByUser(userID int) ([]models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []models.Snippet{*mockSnippet, *mockPrivateSnippet}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (s *StubSnippets) Update(snippet *models.Snippet, editorID int) error {
+	switch snippet.ID {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (s *StubSnippets) Burn(id int) (*models.Snippet, error) {
+	switch id {
+	case 4:
+		return mockBurnSnippet, nil
+	case 5:
+		return mockProtectedSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (s *StubSnippets) DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	return 0, nil
+}
+
+func (s *StubSnippets) Unlock(id int, password string) error {
+	switch {
+	case id == 5 && password == "pa$$word":
+		return nil
+	case id == 5:
+		return models.ErrInvalidCredentials
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (s *StubSnippets) ByTag(tag string, page int, pageSize int) ([]models.Snippet, int, error) {
+	switch tag {
+	case "haiku", "nature":
+		return []models.Snippet{*mockSnippet}, 1, nil
+	default:
+		return nil, 0, nil
+	}
+}
+
+func (s *StubSnippets) ByAuthor(userID int, page int, pageSize int) ([]models.Snippet, int, error) {
+	if userID != 1 || page > 1 {
+		return nil, 0, nil
+	}
+
+	return []models.Snippet{*mockSnippet}, 1, nil
+}
+
+func (s *StubSnippets) TagCounts(limit int) ([]models.TagCount, error) {
+	return []models.TagCount{{Name: "haiku", Count: 3}, {Name: "nature", Count: 1}}, nil
+}
+
+func (s *StubSnippets) StarredBy(userID int, page int, pageSize int) ([]models.Snippet, int, error) {
+	if userID != 1 || page > 1 {
+		return nil, 0, nil
+	}
+
+	return []models.Snippet{*mockSnippet}, 1, nil
+}
+
+func (s *StubSnippets) MostStarred(since time.Time, limit int) ([]models.Snippet, error) {
 	return []models.Snippet{*mockSnippet}, nil
 }
+
+func (s *StubSnippets) Delete(id int) error {
+	switch id {
+	case 1, 3:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (s *StubSnippets) Search(query string, page int, pageSize int) ([]models.SearchResult, int, error) {
+	if !strings.Contains(strings.ToLower(mockSnippet.Content), strings.ToLower(query)) || page > 1 {
+		return nil, 0, nil
+	}
+
+	return []models.SearchResult{{
+		Snippet:  *mockSnippet,
+		Rank:     1,
+		Headline: strings.ReplaceAll(mockSnippet.Content, query, models.HeadlineStartSel+query+models.HeadlineStopSel),
+	}}, 1, nil
+}
+
+func (s *StubSnippets) Revisions(id int) ([]models.Revision, error) {
+	switch id {
+	case 1:
+		return mockRevisions, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (s *StubSnippets) Revision(id int, n int) (*models.Revision, error) {
+	if id == 1 && n >= 1 && n <= len(mockRevisions) {
+		return &mockRevisions[n-1], nil
+	}
+
+	return nil, models.ErrNoRecord
+}
@@ -0,0 +1,11 @@
+package mock
+
+type StubStars struct{}
+
+func (s *StubStars) Toggle(userID int, snippetID int) (bool, error) {
+	return !(userID == 1 && snippetID == 1), nil
+}
+
+func (s *StubStars) Starred(userID int, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == 1, nil
+}
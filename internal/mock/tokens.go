@@ -0,0 +1,73 @@
+package mock
+
+import (
+	"sort"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+var mockTokens = map[string]*models.Token{
+	"sbp_alicereadwrite": {
+		ID:       1,
+		UserID:   1,
+		Name:     "deploy script",
+		Scopes:   []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+		Created:  time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC),
+		LastUsed: time.Date(2023, time.May, 11, 8, 30, 0, 0, time.UTC),
+	},
+	"sbp_bobreadonly": {
+		ID:      2,
+		UserID:  2,
+		Name:    "editor plugin",
+		Scopes:  []string{models.ScopeSnippetsRead},
+		Expires: time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Created: time.Date(2023, time.May, 11, 20, 0, 0, 0, time.UTC),
+	},
+	"sbp_bobwriteonly": {
+		ID:      3,
+		UserID:  2,
+		Name:    "uploader",
+		Scopes:  []string{models.ScopeSnippetsWrite},
+		Created: time.Date(2023, time.May, 11, 20, 0, 0, 0, time.UTC),
+	},
+}
+
+type StubTokens struct{}
+
+func (s *StubTokens) Insert(t *models.Token) (string, error) {
+	t.ID = 4
+	t.Created = time.Now()
+	return "sbp_newtoken", nil
+}
+
+func (s *StubTokens) ByUser(userID int) ([]models.Token, error) {
+	var tokens []models.Token
+	for _, t := range mockTokens {
+		if t.UserID == userID {
+			tokens = append(tokens, *t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	return tokens, nil
+}
+
+func (s *StubTokens) Delete(id int, userID int) error {
+	for _, t := range mockTokens {
+		if t.ID == id && t.UserID == userID {
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (s *StubTokens) Authenticate(plaintext string) (*models.Token, error) {
+	t, ok := mockTokens[plaintext]
+	if !ok {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	return t, nil
+}
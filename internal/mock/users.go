@@ -14,12 +14,22 @@ var mockUser = &models.User{
 	Created:        time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC),
 }
 
+var mockOtherUser = &models.User{
+	ID:             2,
+	Name:           "bob",
+	Email:          "bob@example.com",
+	HashedPassword: []byte{},
+	Created:        time.Date(2023, time.May, 11, 20, 0, 0, 0, time.UTC),
+}
+
 type StubUsers struct{}
 
 func (s *StubUsers) Get(id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockOtherUser, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -38,13 +48,16 @@ func (s *StubUsers) Authenticate(email string, password string) (int, error) {
 	if email == "alice@example.com" && password == "pa$$word" {
 		return 1, nil
 	}
+	if email == "bob@example.com" && password == "pa$$word" {
+		return 2, nil
+	}
 
 	return 0, models.ErrInvalidCredentials
 }
 
 func (s *StubUsers) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 2:
 		return true, nil
 	default:
 		return false, nil
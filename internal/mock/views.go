@@ -0,0 +1,29 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+type StubViews struct{}
+
+func (s *StubViews) Record(ctx context.Context, b *models.ViewBatch) error {
+	return nil
+}
+
+func (s *StubViews) Stats(snippetID int, since time.Time, referrers int) (*models.ViewStats, error) {
+	if snippetID != 1 {
+		return &models.ViewStats{}, nil
+	}
+
+	return &models.ViewStats{
+		Total: 12,
+		Days: []models.DayStats{
+			{Day: time.Date(2023, time.May, 11, 0, 0, 0, 0, time.UTC), Views: 7, Visitors: 3},
+			{Day: time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC), Views: 5, Visitors: 5},
+		},
+		Referrers: []models.ReferrerCount{{SnippetID: 1, Referrer: "news.ycombinator.com", Views: 4}},
+	}, nil
+}
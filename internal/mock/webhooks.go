@@ -0,0 +1,80 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/models"
+)
+
+var mockWebhook = models.Webhook{
+	ID:      1,
+	UserID:  1,
+	URL:     "https://ci.example.com/hooks/snippetbox",
+	Secret:  "a very secret secret",
+	Events:  []string{models.EventSnippetCreated, models.EventSnippetExpired},
+	Created: time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC),
+}
+
+var mockDeliveryAttempts = []models.DeliveryAttempt{
+	{
+		ID:         2,
+		DeliveryID: 1,
+		WebhookID:  1,
+		URL:        mockWebhook.URL,
+		Event:      models.EventSnippetCreated,
+		Attempt:    2,
+		StatusCode: 204,
+		Created:    time.Date(2023, time.May, 10, 20, 1, 0, 0, time.UTC),
+	},
+	{
+		ID:         1,
+		DeliveryID: 1,
+		WebhookID:  1,
+		URL:        mockWebhook.URL,
+		Event:      models.EventSnippetCreated,
+		Attempt:    1,
+		Error:      "connection refused",
+		Created:    time.Date(2023, time.May, 10, 20, 0, 0, 0, time.UTC),
+	},
+}
+
+type StubWebhooks struct{}
+
+func (s *StubWebhooks) Insert(w *models.Webhook) error {
+	w.ID = 2
+	w.Created = time.Now()
+	return nil
+}
+
+func (s *StubWebhooks) ByUser(userID int) ([]models.Webhook, error) {
+	if userID == mockWebhook.UserID {
+		return []models.Webhook{mockWebhook}, nil
+	}
+
+	return nil, nil
+}
+
+func (s *StubWebhooks) Delete(id int, userID int) error {
+	if id == mockWebhook.ID && userID == mockWebhook.UserID {
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (s *StubWebhooks) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.Delivery, error) {
+	return nil, nil
+}
+
+func (s *StubWebhooks) RecordAttempt(ctx context.Context, a *models.DeliveryAttempt, retryAt time.Time) error {
+	return nil
+}
+
+func (s *StubWebhooks) Attempts(userID int, limit int) ([]models.DeliveryAttempt, error) {
+	if userID == mockWebhook.UserID {
+		return mockDeliveryAttempts, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Comment is a comment on a snippet. Replies point to the comment they
+// answer with ParentID, which is 0 for top level comments.
+type Comment struct {
+	ID        int
+	SnippetID int
+	ParentID  int
+	UserID    int
+	UserName  string
+	Body      string
+	Created   time.Time
+}
+
+type Comments interface {
+	Insert(c *Comment) error
+	Get(id int) (*Comment, error)
+	BySnippet(snippetID int) ([]Comment, error)
+	Delete(id int) error
+}
+
+type CommentDB struct {
+	DB *sql.DB
+}
+
+const commentColumns = "c.id, c.snippet_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.body, c.created"
+
+func commentFields(c *Comment) []any {
+	return []any{&c.ID, &c.SnippetID, &c.ParentID, &c.UserID, &c.UserName, &c.Body, &c.Created}
+}
+
+// Insert stores a new comment and fills in its ID and creation time. It
+// returns ErrNoRecord when the comment replies to a comment which isn't on
+// the same snippet.
+func (db *CommentDB) Insert(c *Comment) error {
+	stmt := `INSERT INTO comments (snippet_id, parent_id, user_id, body, created)
+	SELECT $1::int, NULLIF($2::int, 0), $3::int, $4::text, NOW()
+	WHERE $2 = 0 OR EXISTS (SELECT 1 FROM comments p WHERE p.id = $2 AND p.snippet_id = $1)
+	RETURNING id, created`
+	err := db.DB.QueryRow(stmt, c.SnippetID, c.ParentID, c.UserID, c.Body).Scan(&c.ID, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+
+		return fmt.Errorf("models: insert a comment: %s", err)
+	}
+
+	return nil
+}
+
+func (db *CommentDB) Get(id int) (*Comment, error) {
+	var c Comment
+	stmt := `SELECT ` + commentColumns + ` FROM comments c
+	INNER JOIN users u ON u.id = c.user_id
+	WHERE c.id = $1`
+	err := db.DB.QueryRow(stmt, id).Scan(commentFields(&c)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, fmt.Errorf("models: select a comment: %s", err)
+	}
+
+	return &c, nil
+}
+
+// BySnippet returns every comment on a snippet, oldest first.
+func (db *CommentDB) BySnippet(snippetID int) ([]Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c
+	INNER JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = $1 ORDER BY c.created, c.id`
+	row, err := db.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, fmt.Errorf("models: select comments of a snippet: %s", err)
+	}
+	defer row.Close()
+
+	var comments []Comment
+	for row.Next() {
+		var c Comment
+		err := row.Scan(commentFields(&c)...)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan comment row: %s", err)
+		}
+		comments = append(comments, c)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate comment row: %s", err)
+	}
+
+	return comments, nil
+}
+
+// Delete deletes a comment along with the replies to it.
+func (db *CommentDB) Delete(id int) error {
+	result, err := db.DB.Exec("DELETE FROM comments WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("models: delete a comment: %s", err)
+	}
+
+	return checkAffected(result)
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestComments(t *testing.T) {
+	db := newTestDB(t)
+	m := &CommentDB{db}
+
+	stmt := `INSERT INTO snippets (slug, title, content, created, expires, user_id) VALUES
+	('pondXy12Ab', 'An old silent pond', 'An old silent pond...', NOW(), NOW() + INTERVAL '1 day', 1),
+	('forest34Cd', 'Over the wintry forest', 'Over the wintry forest...', NOW(), NOW() + INTERVAL '1 day', 1)`
+	_, err := db.Exec(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top := &Comment{SnippetID: 1, UserID: 1, Body: "Lovely"}
+	assert.Equal(t, m.Insert(top), nil)
+	reply := &Comment{SnippetID: 1, ParentID: top.ID, UserID: 1, Body: "Thanks"}
+	assert.Equal(t, m.Insert(reply), nil)
+
+	// A reply must be on the same snippet as the comment it answers.
+	stray := &Comment{SnippetID: 2, ParentID: top.ID, UserID: 1, Body: "Wrong snippet"}
+	assert.Equal(t, m.Insert(stray), ErrNoRecord)
+	comments, err := m.BySnippet(2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(comments), 0)
+
+	comments, err = m.BySnippet(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(comments), 2)
+	assert.Equal(t, comments[1].ParentID, top.ID)
+	assert.Equal(t, comments[1].UserName, "Alice Jones")
+
+	// Deleting a comment deletes the replies to it.
+	assert.Equal(t, m.Delete(top.ID), nil)
+	_, err = m.Get(reply.ID)
+	assert.Equal(t, err, ErrNoRecord)
+	assert.Equal(t, m.Delete(top.ID), ErrNoRecord)
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SnippetFile is one of the named files a snippet is made of.
+type SnippetFile struct {
+	Name     string
+	Language string
+	Content  string
+}
+
+// normalizeFiles makes sure s has at least one file and that its content
+// and language mirror its first file, which listings and search rely on.
+// Snippets stored before they could have several files get a single unnamed
+// one made of their content.
+func normalizeFiles(s *Snippet) {
+	if len(s.Files) == 0 {
+		s.Files = []SnippetFile{{Language: s.Language, Content: s.Content}}
+	}
+
+	s.Content = s.Files[0].Content
+	s.Language = s.Files[0].Language
+}
+
+// setFiles replaces the files of a snippet, keeping their order.
+func setFiles(tx *sql.Tx, snippetID int, files []SnippetFile) error {
+	_, err := tx.Exec("DELETE FROM snippet_files WHERE snippet_id = $1", snippetID)
+	if err != nil {
+		return fmt.Errorf("models: delete snippet files: %s", err)
+	}
+
+	stmt := "INSERT INTO snippet_files (snippet_id, position, name, language, content) VALUES ($1, $2, $3, $4, $5)"
+	for i, f := range files {
+		_, err = tx.Exec(stmt, snippetID, i+1, f.Name, f.Language, f.Content)
+		if err != nil {
+			return fmt.Errorf("models: insert a snippet file: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// filesContent is the content of every file of the snippet aliased as s,
+// in order.
+const filesContent = "(SELECT string_agg(f.content, E'\\n' ORDER BY f.position) FROM snippet_files f WHERE f.snippet_id = s.id)"
+
+// indexSnippet sets the search vector of a snippet from its title and every
+// one of its files, once they're saved.
+func indexSnippet(tx *sql.Tx, snippetID int) error {
+	stmt := `UPDATE snippets s SET search_vector =
+		setweight(to_tsvector('english', s.title), 'A') ||
+		setweight(to_tsvector('english', COALESCE(` + filesContent + `, '')), 'B')
+	WHERE s.id = $1`
+	_, err := tx.Exec(stmt, snippetID)
+	if err != nil {
+		return fmt.Errorf("models: index a snippet: %s", err)
+	}
+
+	return nil
+}
+
+// files returns the files of a snippet in order.
+func (db *SnippetDB) files(snippetID int) ([]SnippetFile, error) {
+	stmt := "SELECT name, language, content FROM snippet_files WHERE snippet_id = $1 ORDER BY position"
+	row, err := db.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, fmt.Errorf("models: select snippet files: %s", err)
+	}
+	defer row.Close()
+
+	var files []SnippetFile
+	for row.Next() {
+		var f SnippetFile
+		err := row.Scan(&f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan snippet file row: %s", err)
+		}
+		files = append(files, f)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate snippet file row: %s", err)
+	}
+
+	return files, nil
+}
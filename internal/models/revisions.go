@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Revision is the content of a snippet as it was after it had been created
+// or edited. Revisions of a snippet are numbered from 1. Like a snippet's,
+// its content and language mirror its first file.
+type Revision struct {
+	SnippetID  int
+	Number     int
+	Title      string
+	Content    string
+	Language   string
+	Files      []SnippetFile
+	EditorID   int
+	EditorName string
+	Created    time.Time
+}
+
+// insertRevision snapshots the current title and files of a snippet as its
+// next revision. It's called once the files are saved.
+func insertRevision(tx *sql.Tx, snippetID int, editorID int) error {
+	// The snippet is locked until the transaction ends, so concurrent edits
+	// number their revisions one after the other rather than both taking the
+	// same number.
+	_, err := tx.Exec("SELECT id FROM snippets WHERE id = $1 FOR UPDATE", snippetID)
+	if err != nil {
+		return fmt.Errorf("models: lock a snippet: %s", err)
+	}
+
+	var revisionID int
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, title, content, language, editor_id, created)
+	SELECT s.id, COALESCE((SELECT MAX(r.revision) FROM snippet_revisions r WHERE r.snippet_id = s.id), 0) + 1,
+		s.title, s.content, s.language, $2, NOW()
+	FROM snippets s WHERE s.id = $1 RETURNING id`
+	err = tx.QueryRow(stmt, snippetID, editorID).Scan(&revisionID)
+	if err != nil {
+		return fmt.Errorf("models: insert a snippet revision: %s", err)
+	}
+
+	stmt = `INSERT INTO snippet_revision_files (revision_id, position, name, language, content)
+	SELECT $1, f.position, f.name, f.language, f.content FROM snippet_files f WHERE f.snippet_id = $2`
+	_, err = tx.Exec(stmt, revisionID, snippetID)
+	if err != nil {
+		return fmt.Errorf("models: insert snippet revision files: %s", err)
+	}
+
+	return nil
+}
+
+// revisionFiles fills in the files of revisions of a snippet, or of its n-th
+// revision only when n isn't 0. Revisions saved before snippets could have
+// several files get a single unnamed one made of their content.
+func (db *SnippetDB) revisionFiles(snippetID int, n int, revisions []Revision) error {
+	stmt := `SELECT r.revision, f.name, f.language, f.content FROM snippet_revision_files f
+	INNER JOIN snippet_revisions r ON r.id = f.revision_id
+	WHERE r.snippet_id = $1 AND ($2 = 0 OR r.revision = $2)
+	ORDER BY r.revision, f.position`
+	row, err := db.DB.Query(stmt, snippetID, n)
+	if err != nil {
+		return fmt.Errorf("models: select snippet revision files: %s", err)
+	}
+	defer row.Close()
+
+	files := map[int][]SnippetFile{}
+	for row.Next() {
+		var number int
+		var f SnippetFile
+		err := row.Scan(&number, &f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return fmt.Errorf("models: scan snippet revision file row: %s", err)
+		}
+		files[number] = append(files[number], f)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return fmt.Errorf("models: iterate snippet revision file row: %s", err)
+	}
+
+	for i := range revisions {
+		r := &revisions[i]
+		r.Files = files[r.Number]
+		if len(r.Files) == 0 {
+			r.Files = []SnippetFile{{Language: r.Language, Content: r.Content}}
+		}
+	}
+
+	return nil
+}
+
+const revisionColumns = "r.snippet_id, r.revision, r.title, r.content, r.language, r.editor_id, u.name, r.created"
+
+func revisionFields(r *Revision) []any {
+	return []any{&r.SnippetID, &r.Number, &r.Title, &r.Content, &r.Language, &r.EditorID, &r.EditorName, &r.Created}
+}
+
+// Revisions returns every revision of a snippet, oldest first.
+func (db *SnippetDB) Revisions(id int) ([]Revision, error) {
+	stmt := `SELECT ` + revisionColumns + ` FROM snippet_revisions r
+	INNER JOIN users u ON u.id = r.editor_id
+	WHERE r.snippet_id = $1 ORDER BY r.revision`
+	row, err := db.DB.Query(stmt, id)
+	if err != nil {
+		return nil, fmt.Errorf("models: select snippet revisions: %s", err)
+	}
+	defer row.Close()
+
+	var revisions []Revision
+	for row.Next() {
+		var r Revision
+		err := row.Scan(revisionFields(&r)...)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan snippet revision row: %s", err)
+		}
+		revisions = append(revisions, r)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate snippet revision row: %s", err)
+	}
+
+	err = db.revisionFiles(id, 0, revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision returns the n-th revision of a snippet.
+func (db *SnippetDB) Revision(id int, n int) (*Revision, error) {
+	var r Revision
+	stmt := `SELECT ` + revisionColumns + ` FROM snippet_revisions r
+	INNER JOIN users u ON u.id = r.editor_id
+	WHERE r.snippet_id = $1 AND r.revision = $2`
+	err := db.DB.QueryRow(stmt, id, n).Scan(revisionFields(&r)...)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, fmt.Errorf("models: select a snippet revision: %s", err)
+	}
+
+	revisions := []Revision{r}
+	err = db.revisionFiles(id, n, revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revisions[0], nil
+}
@@ -1,77 +1,593 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/big"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// VisibilityPublic snippets are listed on the home page and in search.
+	VisibilityPublic = "public"
+	// VisibilityUnlisted snippets are not listed but anyone with the link can
+	// read them.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPrivate snippets can only be read by their author.
+	VisibilityPrivate = "private"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
+	ID    int
+	Slug  string
+	Title string
+	// Content and Language mirror the first of Files.
+	Content  string
+	Language string
+	// Files is only filled in for a single snippet, not for listings.
+	Files   []SnippetFile
 	Created time.Time
-	Expires time.Time
+	// Updated is when the latest revision was made, Created if there's none.
+	Updated time.Time
+	// Expires is the zero time for snippets which never expire.
+	Expires          time.Time
+	BurnAfterReading bool
+	// Password, when set on insert or update, protects the snippet with a
+	// new password. It's never read back from the database.
+	Password string
+	// Protected reports whether reading the snippet needs a password.
+	// Clearing it on update removes the password.
+	Protected  bool
+	UserID     int
+	UserName   string
+	Visibility string
+	// Tags are sorted by name.
+	Tags []string
+	// ForkedFrom is the ID of the snippet this one was forked from, 0 if it
+	// wasn't forked.
+	ForkedFrom     int
+	ForkedFromSlug string
+	ForkCount      int
+	StarCount      int
+}
+
+// VisibleTo reports whether the user with the given ID (0 for anonymous
+// users) is allowed to read the snippet.
+func (s *Snippet) VisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || s.UserID == userID
+}
+
+type SearchResult struct {
+	Snippet
+	Rank float64
+	// Headline is an excerpt of the snippet content where the matched terms
+	// are wrapped between HeadlineStartSel and HeadlineStopSel.
+	Headline string
 }
 
+const (
+	HeadlineStartSel = "\x02"
+	HeadlineStopSel  = "\x03"
+)
+
 type Snippets interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(s *Snippet) error
 	Get(id int) (*Snippet, error)
-	Latest() ([]Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	List(page int, pageSize int) ([]Snippet, int, error)
+	ByUser(userID int) ([]Snippet, error)
+	Search(query string, page int, pageSize int) ([]SearchResult, int, error)
+	Update(s *Snippet, editorID int) error
+	Revisions(id int) ([]Revision, error)
+	Revision(id int, n int) (*Revision, error)
+	Delete(id int) error
+	Burn(id int) (*Snippet, error)
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error)
+	Unlock(id int, password string) error
+	ByTag(tag string, page int, pageSize int) ([]Snippet, int, error)
+	ByAuthor(userID int, page int, pageSize int) ([]Snippet, int, error)
+	TagCounts(limit int) ([]TagCount, error)
+	StarredBy(userID int, page int, pageSize int) ([]Snippet, int, error)
+	MostStarred(since time.Time, limit int) ([]Snippet, error)
 }
 
 type SnippetDB struct {
 	DB *sql.DB
 }
 
-func (db *SnippetDB) Insert(title string, content string, expires int) (int, error) {
-	stmt := "INSERT INTO snippets (title, content, created, expires) values($1, $2, NOW(), NOW() + $3 * INTERVAL '1 DAY') RETURNING id"
-	var id int
-	err := db.DB.QueryRow(stmt, title, content, expires).Scan(&id)
+const snippetColumns = "s.id, s.slug, s.title, s.content, s.language, s.created, " + updatedColumn + ", s.expires, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, u.name, s.visibility, COALESCE(s.forked_from, 0), " + tagsColumn + ", " + starsColumn
+
+// updatedColumn selects when the snippet aliased as s was last edited.
+const updatedColumn = "COALESCE((SELECT max(r.created) FROM snippet_revisions r WHERE r.snippet_id = s.id), s.created)"
+
+// notExpired is the condition unexpired snippets aliased as s satisfy.
+const notExpired = "(s.expires IS NULL OR s.expires > NOW())"
+
+// listed is the condition snippets aliased as s satisfy to be shown in
+// listings: unexpired, public, not password protected and not meant to be
+// read only once.
+const listed = notExpired + " AND s.visibility = 'public' AND s.hashed_password IS NULL AND NOT s.burn_after_reading"
+
+func snippetFields(s *Snippet) []any {
+	return []any{&s.ID, &s.Slug, &s.Title, &s.Content, &s.Language, &s.Created, &s.Updated, nullTime{&s.Expires}, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.UserName, &s.Visibility, &s.ForkedFrom, pq.Array(&s.Tags), &s.StarCount}
+}
+
+const (
+	slugLength      = 10
+	slugLetters     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	slugAlphabet    = slugLetters + "0123456789"
+	slugInsertTries = 5
+)
+
+// Insert stores a new snippet along with its first revision and fills in
+// its ID, slug and creation time.
+func (db *SnippetDB) Insert(s *Snippet) error {
+	normalizeFiles(s)
+	hashedPassword, err := snippetPasswordParam(s)
+	if err != nil {
+		return err
+	}
+
+	for try := 1; ; try++ {
+		err := db.insert(s, hashedPassword)
+		if err == errSlugConflict && try < slugInsertTries {
+			continue
+		}
+
+		return err
+	}
+}
+
+var errSlugConflict = errors.New("models: slug conflict")
+
+func (db *SnippetDB) insert(s *Snippet, hashedPassword sql.NullString) error {
+	slug, err := newSlug()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("models: begin a transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (slug, title, content, language, created, expires, burn_after_reading, hashed_password, user_id, visibility, forked_from)
+	VALUES ($1, $2, $3, $4, NOW(), $5, $6, $7, $8, $9, NULLIF($10, 0)) RETURNING id, created`
+	err = tx.QueryRow(stmt, slug, s.Title, s.Content, s.Language, expiresParam(s.Expires), s.BurnAfterReading, hashedPassword, s.UserID, s.Visibility, s.ForkedFrom).Scan(&s.ID, &s.Created)
+	if err != nil {
+		var postgresErr *pq.Error
+		if errors.As(err, &postgresErr); postgresErr != nil {
+			if postgresErr.Code == "23505" && postgresErr.Constraint == "snippets_uc_slug" {
+				return errSlugConflict
+			}
+		}
+
+		return fmt.Errorf("models: insert a snippet: %s", err)
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = setFiles(tx, s.ID, s.Files)
+	if err != nil {
+		return err
+	}
+
+	err = indexSnippet(tx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, s.ID, s.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = enqueueWebhooks(tx, EventSnippetCreated, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	if err != nil {
-		return 0, fmt.Errorf("models: insert a snippet: %s", err)
+		return fmt.Errorf("models: commit a snippet: %s", err)
 	}
 
-	return id, nil
+	s.Slug = slug
+	s.Protected = hashedPassword.Valid
+	return nil
+}
+
+// newSlug returns a random base62 slug. It always starts with a letter so it
+// can't be mistaken for a numeric ID.
+func newSlug() (string, error) {
+	slug := make([]byte, slugLength)
+	for i := range slug {
+		alphabet := slugAlphabet
+		if i == 0 {
+			alphabet = slugLetters
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("models: generate a slug: %s", err)
+		}
+		slug[i] = alphabet[n.Int64()]
+	}
+
+	return string(slug), nil
 }
 
 func (db *SnippetDB) Get(id int) (*Snippet, error) {
+	return db.get("s.id = $1", id)
+}
+
+func (db *SnippetDB) GetBySlug(slug string) (*Snippet, error) {
+	return db.get("s.slug = $1", slug)
+}
+
+func (db *SnippetDB) get(cond string, arg any) (*Snippet, error) {
 	var s Snippet
-	stmt := "SELECT id, title, content, created, expires FROM snippets WHERE expires > NOW() AND id = $1"
-	err := db.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	stmt := `SELECT ` + snippetColumns + `, COALESCE(p.slug, ''),
+		(SELECT count(*) FROM snippets f WHERE f.forked_from = s.id AND (f.expires IS NULL OR f.expires > NOW()))
+	FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	LEFT JOIN snippets p ON p.id = s.forked_from
+	WHERE ` + notExpired + ` AND ` + cond
+	err := db.DB.QueryRow(stmt, arg).Scan(append(snippetFields(&s), &s.ForkedFromSlug, &s.ForkCount)...)
 
 	switch err {
 	case sql.ErrNoRows:
 		return nil, ErrNoRecord
 	case nil:
-		return &s, nil
 	default:
 		return nil, fmt.Errorf("models: select a snippet: %s", err)
 	}
+
+	s.Files, err = db.files(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizeFiles(&s)
+	return &s, nil
 }
 
-func (db *SnippetDB) Latest() ([]Snippet, error) {
-	stmt := "SELECT id, title, content, created, expires FROM snippets WHERE expires > NOW() ORDER BY created DESC LIMIT 10"
-	row, err := db.DB.Query(stmt)
+// List returns the page-th page (starting from 1) of unexpired public
+// snippets, newest first, together with the total number of them.
+func (db *SnippetDB) List(page int, pageSize int) ([]Snippet, int, error) {
+	return db.listPublic("TRUE", nil, page, pageSize)
+}
+
+// listPublic returns a page of the listed snippets satisfying cond, whose
+// placeholders are filled by args, newest first, along with how many there
+// are in total.
+func (db *SnippetDB) listPublic(cond string, args []any, page int, pageSize int) ([]Snippet, int, error) {
+	stmt := `SELECT count(*) OVER(), ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + listed + ` AND ` + cond + `
+	ORDER BY s.created DESC, s.id DESC ` + fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	row, err := db.DB.Query(stmt, append(args, pageSize, (page-1)*pageSize)...)
 	if err != nil {
-		return nil, fmt.Errorf("models: select lastest snippets: %s", err)
+		return nil, 0, fmt.Errorf("models: select a page of snippets: %s", err)
 	}
 	defer row.Close()
 
+	var total int
 	var snippets []Snippet
 	for row.Next() {
 		var s Snippet
-		err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := row.Scan(append([]any{&total}, snippetFields(&s)...)...)
 		if err != nil {
-			return nil, fmt.Errorf("models: scan snippet row: %s", err)
+			return nil, 0, fmt.Errorf("models: scan snippet row: %s", err)
 		}
 		snippets = append(snippets, s)
 	}
 
 	err = row.Err()
+	if err != nil {
+		return nil, 0, fmt.Errorf("models: iterate snippet row: %s", err)
+	}
+
+	if total == 0 && page > 1 {
+		total, err = db.count(cond, args)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return snippets, total, nil
+}
+
+func (db *SnippetDB) count(cond string, args []any) (int, error) {
+	var total int
+	stmt := "SELECT count(*) FROM snippets s WHERE " + listed + " AND " + cond
+	err := db.DB.QueryRow(stmt, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("models: count snippets: %s", err)
+	}
+
+	return total, nil
+}
+
+// ByAuthor returns a page of the listed snippets of a user, newest first,
+// along with how many there are in total.
+func (db *SnippetDB) ByAuthor(userID int, page int, pageSize int) ([]Snippet, int, error) {
+	return db.listPublic("s.user_id = $1", []any{userID}, page, pageSize)
+}
+
+// ByUser returns every unexpired snippet of a user, whatever its visibility.
+func (db *SnippetDB) ByUser(userID int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.user_id = $1 ORDER BY s.created DESC`
+	row, err := db.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("models: select snippets of a user: %s", err)
+	}
+	defer row.Close()
+
+	return scanSnippets(row)
+}
+
+// Search does a full-text search over the title and files of listed
+// snippets, best matches first. query is in the websearch_to_tsquery syntax.
+func (db *SnippetDB) Search(query string, page int, pageSize int) ([]SearchResult, int, error) {
+	stmt := `SELECT count(*) OVER(), ` + snippetColumns + `,
+		ts_rank(s.search_vector, q.query) AS rank,
+		ts_headline('english', ` + filesContent + `, q.query, format('StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=20, MinWords=8', chr(2), chr(3)))
+	FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	CROSS JOIN websearch_to_tsquery('english', $1) AS q(query)
+	WHERE ` + listed + ` AND s.search_vector @@ q.query
+	ORDER BY rank DESC, s.created DESC, s.id DESC LIMIT $2 OFFSET $3`
+	row, err := db.DB.Query(stmt, query, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("models: search snippets: %s", err)
+	}
+	defer row.Close()
+
+	var total int
+	var results []SearchResult
+	for row.Next() {
+		var r SearchResult
+		dest := append([]any{&total}, snippetFields(&r.Snippet)...)
+		err := row.Scan(append(dest, &r.Rank, &r.Headline)...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("models: scan search result row: %s", err)
+		}
+		results = append(results, r)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, 0, fmt.Errorf("models: iterate search result row: %s", err)
+	}
+
+	if total == 0 && page > 1 {
+		total, err = db.count("s.search_vector @@ websearch_to_tsquery('english', $1)", []any{query})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return results, total, nil
+}
+
+// Update changes a snippet and records the new content as its next
+// revision, so previous content is never lost.
+func (db *SnippetDB) Update(s *Snippet, editorID int) error {
+	normalizeFiles(s)
+	hashedPassword, err := snippetPasswordParam(s)
+	if err != nil {
+		return err
+	}
+	// The current password is kept unless a new one is given or the
+	// protection is removed.
+	keepPassword := s.Protected && !hashedPassword.Valid
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("models: begin a transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets s SET title = $1, content = $2, language = $3, expires = $4, burn_after_reading = $5, visibility = $6,
+		hashed_password = CASE WHEN $7 THEN s.hashed_password ELSE $8 END
+	WHERE ` + notExpired + ` AND s.id = $9`
+	result, err := tx.Exec(stmt, s.Title, s.Content, s.Language, expiresParam(s.Expires), s.BurnAfterReading, s.Visibility, keepPassword, hashedPassword, s.ID)
+	if err != nil {
+		return fmt.Errorf("models: update a snippet: %s", err)
+	}
+
+	err = checkAffected(result)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = setFiles(tx, s.ID, s.Files)
+	if err != nil {
+		return err
+	}
+
+	err = indexSnippet(tx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, s.ID, editorID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("models: commit a snippet update: %s", err)
+	}
+
+	return nil
+}
+
+// Unlock checks the password of a protected snippet. It returns
+// ErrInvalidCredentials when the password doesn't match.
+func (db *SnippetDB) Unlock(id int, password string) error {
+	var hashedPassword []byte
+	stmt := "SELECT s.hashed_password FROM snippets s WHERE " + notExpired + " AND s.id = $1 AND s.hashed_password IS NOT NULL"
+	err := db.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+
+		return fmt.Errorf("models: select a snippet password: %s", err)
+	}
+
+	return compareHashedPassword(hashedPassword, []byte(password))
+}
+
+// snippetPasswordParam hashes the new password of s, if any, into a value for
+// the hashed_password column.
+func snippetPasswordParam(s *Snippet) (sql.NullString, error) {
+	if s.Password == "" {
+		return sql.NullString{}, nil
+	}
+
+	hashedPassword, err := hashPassword(s.Password)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(hashedPassword), Valid: true}, nil
+}
+
+// Burn deletes a burn-after-reading snippet and returns it. When several
+// readers race to burn the same snippet only one of them gets it, the others
+// get ErrNoRecord.
+func (db *SnippetDB) Burn(id int) (*Snippet, error) {
+	// The files are gone along with the snippet, so they're loaded first.
+	files, err := db.files(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Snippet
+	stmt := `WITH s AS (
+		DELETE FROM snippets s WHERE s.id = $1 AND s.burn_after_reading AND ` + notExpired + ` RETURNING *
+	)
+	SELECT ` + snippetColumns + ` FROM s
+	INNER JOIN users u ON u.id = s.user_id`
+	err = db.DB.QueryRow(stmt, id).Scan(snippetFields(&s)...)
+
+	switch err {
+	case sql.ErrNoRows:
+		return nil, ErrNoRecord
+	case nil:
+	default:
+		return nil, fmt.Errorf("models: burn a snippet: %s", err)
+	}
+
+	s.Files = files
+	normalizeFiles(&s)
+	return &s, nil
+}
+
+func (db *SnippetDB) Delete(id int) error {
+	stmt := "DELETE FROM snippets WHERE id = $1"
+	result, err := db.DB.Exec(stmt, id)
+	if err != nil {
+		return fmt.Errorf("models: delete a snippet: %s", err)
+	}
+
+	return checkAffected(result)
+}
+
+// DeleteExpired deletes at most limit snippets which expired before the given
+// time, oldest first, and returns how many were deleted. The expired events
+// of the deleted snippets are queued for webhooks in the same statement, so
+// none are lost.
+func (db *SnippetDB) DeleteExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	stmt := `WITH deleted AS (
+		DELETE FROM snippets WHERE id IN (
+			SELECT id FROM snippets WHERE expires <= $1 ORDER BY expires LIMIT $2
+		) RETURNING id, slug, title, visibility, expires, user_id
+	), queued AS (
+		INSERT INTO webhook_deliveries (webhook_id, event, snippet_id, slug, title, visibility, expires, next_attempt, created)
+		SELECT w.id, $3::text, d.id, d.slug, d.title, d.visibility, d.expires, NOW(), NOW()
+		FROM deleted d INNER JOIN webhooks w ON w.user_id = d.user_id AND $3 = ANY(w.events)
+	)
+	SELECT count(*) FROM deleted`
+	var n int
+	err := db.DB.QueryRowContext(ctx, stmt, before.UTC(), limit, EventSnippetExpired).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("models: delete expired snippets: %s", err)
+	}
+
+	return n, nil
+}
+
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("models: count affected rows: %s", err)
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func scanSnippets(row *sql.Rows) ([]Snippet, error) {
+	var snippets []Snippet
+	for row.Next() {
+		var s Snippet
+		err := row.Scan(snippetFields(&s)...)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan snippet row: %s", err)
+		}
+		snippets = append(snippets, s)
+	}
+
+	err := row.Err()
 	if err != nil {
 		return nil, fmt.Errorf("models: iterate snippet row: %s", err)
 	}
 
 	return snippets, nil
 }
+
+// nullTime scans a nullable timestamp into t, NULL becoming the zero time.
+type nullTime struct {
+	t *time.Time
+}
+
+func (nt nullTime) Scan(value any) error {
+	var v sql.NullTime
+	err := v.Scan(value)
+	if err != nil {
+		return err
+	}
+
+	*nt.t = v.Time
+	return nil
+}
+
+// expiresParam turns the zero expiry time of never expiring snippets into
+// NULL, and sends the others in UTC so the zone of the app doesn't matter.
+func expiresParam(expires time.Time) sql.NullTime {
+	return sql.NullTime{Time: expires.UTC(), Valid: !expires.IsZero()}
+}
@@ -0,0 +1,369 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		slug, err := newSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(slug), slugLength)
+		assert.Equal(t, strings.ContainsRune(slugLetters, rune(slug[0])), true)
+		assert.Equal(t, strings.Trim(slug, slugAlphabet), "")
+		assert.Equal(t, seen[slug], false)
+		seen[slug] = true
+	}
+}
+
+func TestSnippetDeleteExpired(t *testing.T) {
+	db := newTestDB(t)
+
+	stmt := `INSERT INTO snippets (slug, title, content, created, expires, user_id) VALUES
+	('expiredA01', 'Expired', 'Expired', NOW(), NOW() - INTERVAL '2 days', 1),
+	('expiredB02', 'Expired', 'Expired', NOW(), NOW() - INTERVAL '1 day', 1),
+	('expiredC03', 'Expired', 'Expired', NOW(), NOW() - INTERVAL '1 hour', 1),
+	('currentD04', 'Current', 'Current', NOW(), NOW() + INTERVAL '1 day', 1),
+	('foreverE05', 'Forever', 'Forever', NOW(), NULL, 1)`
+	_, err := db.Exec(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &SnippetDB{db}
+	n, err := m.DeleteExpired(context.Background(), time.Now(), 2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 2)
+
+	n, err = m.DeleteExpired(context.Background(), time.Now(), 2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 1)
+
+	var left int
+	err = db.QueryRow("SELECT count(*) FROM snippets").Scan(&left)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, left, 2)
+}
+
+func TestSnippetExpiresInAnyZone(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetDB{db}
+
+	// The same instant is kept whatever the zone the expiry was worked out
+	// in, so neither snippet is expired yet.
+	for _, offset := range []int{-10, 10} {
+		zone := time.FixedZone(fmt.Sprintf("UTC%+d", offset), offset*60*60)
+		s := &Snippet{Title: "Soon", Files: []SnippetFile{{Content: "Soon"}}, Expires: time.Now().In(zone).Add(10 * time.Minute), UserID: 1, Visibility: VisibilityPublic}
+		err := m.Insert(s)
+		assert.Equal(t, err, nil)
+
+		got, err := m.Get(s.ID)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, got.Expires.Sub(s.Expires).Abs() < time.Second, true)
+	}
+
+	n, err := m.DeleteExpired(context.Background(), time.Now().In(time.FixedZone("UTC-10", -10*60*60)), 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 0)
+	n, err = m.DeleteExpired(context.Background(), time.Now().Add(time.Hour), 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 2)
+}
+
+func TestSnippetTags(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetDB{db}
+
+	s := &Snippet{
+		Title:      "Tagged",
+		Content:    "Tagged",
+		Language:   "go",
+		UserID:     1,
+		Visibility: VisibilityPublic,
+		Tags:       []string{"http", "go"},
+	}
+	err := m.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Get(s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.Join(got.Tags, ","), "go,http")
+
+	snippets, total, err := m.ByTag("http", 1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, len(snippets), 1)
+
+	s.Tags = []string{"go"}
+	err = m.Update(s, 1)
+	assert.Equal(t, err, nil)
+
+	counts, err := m.TagCounts(10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(counts), 1)
+	assert.Equal(t, counts[0], TagCount{Name: "go", Count: 1})
+}
+
+func TestSnippetListingsSkipProtected(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetDB{db}
+
+	open := &Snippet{Title: "Open", Content: "Open", UserID: 1, Visibility: VisibilityPublic, Tags: []string{"go"}}
+	protected := &Snippet{Title: "Protected", Content: "Protected", UserID: 1, Visibility: VisibilityPublic, Tags: []string{"go", "secret"}, Password: "pa$$word"}
+	for _, s := range []*Snippet{open, protected} {
+		err := m.Insert(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := db.Exec("INSERT INTO stars (user_id, snippet_id, created) VALUES (1, $1, NOW()), (1, $2, NOW())", open.ID, protected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets, total, err := m.List(1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, snippets[0].Slug, open.Slug)
+
+	snippets, total, err = m.ByTag("go", 1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, snippets[0].Slug, open.Slug)
+
+	counts, err := m.TagCounts(10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(counts), 1)
+	assert.Equal(t, counts[0], TagCount{Name: "go", Count: 1})
+
+	snippets, err = m.MostStarred(time.Now().Add(-time.Hour), 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Slug, open.Slug)
+
+	// The author still sees their own protected snippet among their stars,
+	// but anyone else who starred it doesn't.
+	_, err = db.Exec(`INSERT INTO users (name, email, hashed_password, created)
+	VALUES ('Bob Smith', 'bob@example.com', 'x', NOW())`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO stars (user_id, snippet_id, created) VALUES (2, $1, NOW()), (2, $2, NOW())", open.ID, protected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, total, err = m.StarredBy(1, 1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 2)
+
+	snippets, total, err = m.StarredBy(2, 1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, snippets[0].Slug, open.Slug)
+}
+
+func TestSnippetFiles(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetDB{db}
+
+	s := &Snippet{
+		Title: "Hello server",
+		Files: []SnippetFile{
+			{Name: "main.go", Language: "go", Content: "package main"},
+			{Name: "go.mod", Language: "plaintext", Content: "module hello"},
+		},
+		UserID:     1,
+		Visibility: VisibilityPublic,
+	}
+	err := m.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Get(s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(got.Files), 2)
+	assert.Equal(t, got.Files[1], SnippetFile{Name: "go.mod", Language: "plaintext", Content: "module hello"})
+	assert.Equal(t, got.Content, "package main")
+	assert.Equal(t, got.Language, "go")
+
+	s.Files = s.Files[1:]
+	err = m.Update(s, 1)
+	assert.Equal(t, err, nil)
+
+	got, err = m.Get(s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(got.Files), 1)
+	assert.Equal(t, got.Content, "module hello")
+}
+
+func TestSnippetRevisionFiles(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetDB{db}
+
+	s := &Snippet{
+		Title: "Hello server",
+		Files: []SnippetFile{
+			{Name: "main.go", Language: "go", Content: "package main"},
+			{Name: "server.go", Language: "go", Content: "func serve() {}"},
+		},
+		UserID:     1,
+		Visibility: VisibilityPublic,
+	}
+	err := m.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Files[1].Content = "func listenAndServe() {}"
+	err = m.Update(s, 1)
+	assert.Equal(t, err, nil)
+
+	revisions, err := m.Revisions(s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, len(revisions[0].Files), 2)
+	assert.Equal(t, revisions[0].Files[1].Content, "func serve() {}")
+	assert.Equal(t, revisions[1].Files[1].Content, "func listenAndServe() {}")
+
+	r, err := m.Revision(s.ID, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, r.Files[1], SnippetFile{Name: "server.go", Language: "go", Content: "func serve() {}"})
+
+	// Files after the first are searched too.
+	results, total, err := m.Search("listenAndServe", 1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, results[0].Slug, s.Slug)
+
+	// Past the last page there are no results but the total is still known.
+	results, total, err = m.Search("listenAndServe", 2, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(results), 0)
+	assert.Equal(t, total, 1)
+}
+
+func TestStars(t *testing.T) {
+	db := newTestDB(t)
+	snippets := &SnippetDB{db}
+	stars := &StarDB{db}
+
+	s := &Snippet{
+		Title:      "Starred",
+		Content:    "Starred",
+		Language:   "plaintext",
+		UserID:     1,
+		Visibility: VisibilityPublic,
+	}
+	err := snippets.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starred, err := stars.Toggle(1, s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, starred, true)
+
+	got, err := snippets.Get(s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got.StarCount, 1)
+
+	starredBy, total, err := snippets.StarredBy(1, 1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, total, 1)
+	assert.Equal(t, starredBy[0].ID, s.ID)
+
+	mostStarred, err := snippets.MostStarred(time.Now().Add(-time.Hour), 5)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(mostStarred), 1)
+	assert.Equal(t, mostStarred[0].StarCount, 1)
+
+	starred, err = stars.Toggle(1, s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, starred, false)
+
+	starred, err = stars.Starred(1, s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, starred, false)
+}
+
+func TestViews(t *testing.T) {
+	db := newTestDB(t)
+	snippets := &SnippetDB{db}
+	views := &ViewDB{db}
+
+	s := &Snippet{
+		Title:      "Viewed",
+		Content:    "Viewed",
+		Language:   "plaintext",
+		UserID:     1,
+		Visibility: VisibilityPublic,
+	}
+	err := snippets.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	b := &ViewBatch{
+		Views:     []ViewCount{{SnippetID: s.ID, Day: today, Views: 3}, {SnippetID: s.ID + 100, Day: today, Views: 1}},
+		Visitors:  []Visitor{{SnippetID: s.ID, Day: today, Hash: strings.Repeat("a", 64)}, {SnippetID: s.ID, Day: today, Hash: strings.Repeat("b", 64)}},
+		Referrers: []ReferrerCount{{SnippetID: s.ID, Referrer: "example.com", Views: 2}},
+	}
+	for i := 0; i < 2; i++ {
+		err = views.Record(context.Background(), b)
+		assert.Equal(t, err, nil)
+	}
+
+	stats, err := views.Stats(s.ID, today.AddDate(0, 0, -7), 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stats.Total, 6)
+	assert.Equal(t, len(stats.Days), 1)
+	assert.Equal(t, stats.Days[0].Views, 6)
+	assert.Equal(t, stats.Days[0].Visitors, 2)
+	assert.Equal(t, len(stats.Referrers), 1)
+	assert.Equal(t, stats.Referrers[0].Views, 4)
+}
+
+func TestSnippetConcurrentUpdates(t *testing.T) {
+	db := newTestDB(t)
+	m := &SnippetDB{db}
+
+	s := &Snippet{Title: "Edited", Content: "Edited", UserID: 1, Visibility: VisibilityPublic}
+	err := m.Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const editors = 5
+	errs := make(chan error, editors)
+	for i := 0; i < editors; i++ {
+		edit := *s
+		edit.Content = fmt.Sprintf("Edit %d", i)
+		edit.Files = nil
+		go func() {
+			errs <- m.Update(&edit, 1)
+		}()
+	}
+	for i := 0; i < editors; i++ {
+		assert.Equal(t, <-errs, nil)
+	}
+
+	revisions, err := m.Revisions(s.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(revisions), editors+1)
+	assert.Equal(t, revisions[editors].Number, editors+1)
+}
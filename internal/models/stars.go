@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Stars interface {
+	Toggle(userID int, snippetID int) (bool, error)
+	Starred(userID int, snippetID int) (bool, error)
+}
+
+type StarDB struct {
+	DB *sql.DB
+}
+
+// starsColumn counts the stars of the snippet aliased as s.
+const starsColumn = "(SELECT count(*) FROM stars st WHERE st.snippet_id = s.id)"
+
+// Toggle stars a snippet for a user, or removes the star if the user already
+// starred it, and reports whether the snippet is starred afterwards.
+func (db *StarDB) Toggle(userID int, snippetID int) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("models: begin a transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM stars WHERE user_id = $1 AND snippet_id = $2", userID, snippetID)
+	if err != nil {
+		return false, fmt.Errorf("models: delete a star: %s", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("models: get affected rows: %s", err)
+	}
+
+	starred := n == 0
+	if starred {
+		stmt := `INSERT INTO stars (user_id, snippet_id, created) VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id, snippet_id) DO NOTHING`
+		_, err = tx.Exec(stmt, userID, snippetID)
+		if err != nil {
+			return false, fmt.Errorf("models: insert a star: %s", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("models: commit a transaction: %s", err)
+	}
+
+	return starred, nil
+}
+
+func (db *StarDB) Starred(userID int, snippetID int) (bool, error) {
+	var starred bool
+	stmt := "SELECT EXISTS(SELECT 1 FROM stars WHERE user_id = $1 AND snippet_id = $2)"
+	err := db.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	if err != nil {
+		return false, fmt.Errorf("models: check a star: %s", err)
+	}
+
+	return starred, nil
+}
+
+// StarredBy returns a page of the unexpired snippets a user starred and is
+// still allowed to read, most recently starred first, along with how many
+// there are in total.
+func (db *SnippetDB) StarredBy(userID int, page int, pageSize int) ([]Snippet, int, error) {
+	cond := `s.user_id = $1 OR (s.visibility <> 'private' AND s.hashed_password IS NULL AND NOT s.burn_after_reading)`
+	stmt := `SELECT count(*) OVER(), ` + snippetColumns + ` FROM stars mine
+	INNER JOIN snippets s ON s.id = mine.snippet_id
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE mine.user_id = $1 AND ` + notExpired + ` AND (` + cond + `)
+	ORDER BY mine.created DESC, s.id DESC LIMIT $2 OFFSET $3`
+	row, err := db.DB.Query(stmt, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("models: select starred snippets: %s", err)
+	}
+	defer row.Close()
+
+	var total int
+	var snippets []Snippet
+	for row.Next() {
+		var s Snippet
+		err := row.Scan(append([]any{&total}, snippetFields(&s)...)...)
+		if err != nil {
+			return nil, 0, fmt.Errorf("models: scan snippet row: %s", err)
+		}
+		snippets = append(snippets, s)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, 0, fmt.Errorf("models: iterate snippet row: %s", err)
+	}
+
+	if total == 0 && page > 1 {
+		stmt := `SELECT count(*) FROM stars mine
+		INNER JOIN snippets s ON s.id = mine.snippet_id
+		WHERE mine.user_id = $1 AND ` + notExpired + ` AND (` + cond + `)`
+		err = db.DB.QueryRow(stmt, userID).Scan(&total)
+		if err != nil {
+			return nil, 0, fmt.Errorf("models: count starred snippets: %s", err)
+		}
+	}
+
+	return snippets, total, nil
+}
+
+// MostStarred returns the limit listed snippets which got the most stars
+// since the given time, most starred first.
+func (db *SnippetDB) MostStarred(since time.Time, limit int) ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	INNER JOIN (
+		SELECT snippet_id, count(*) AS n FROM stars
+		WHERE created >= $1 GROUP BY snippet_id
+	) recent ON recent.snippet_id = s.id
+	WHERE ` + listed + `
+	ORDER BY recent.n DESC, s.created DESC, s.id DESC LIMIT $2`
+	row, err := db.DB.Query(stmt, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("models: select most starred snippets: %s", err)
+	}
+	defer row.Close()
+
+	return scanSnippets(row)
+}
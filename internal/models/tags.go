@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// TagCount is a tag along with how many listed snippets carry it.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// tagsColumn selects the tag names of the snippet aliased as s, sorted.
+const tagsColumn = `ARRAY(SELECT t.name FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id = s.id ORDER BY t.name)`
+
+// setTags replaces the tags of a snippet, creating the tags which don't exist
+// yet.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = $1", snippetID)
+	if err != nil {
+		return fmt.Errorf("models: delete snippet tags: %s", err)
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	stmt := "INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING"
+	_, err = tx.Exec(stmt, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("models: insert tags: %s", err)
+	}
+
+	stmt = "INSERT INTO snippet_tags (snippet_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2)"
+	_, err = tx.Exec(stmt, snippetID, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("models: insert snippet tags: %s", err)
+	}
+
+	return nil
+}
+
+// ByTag returns a page of the listed snippets carrying a tag, newest first,
+// along with how many there are in total.
+func (db *SnippetDB) ByTag(tag string, page int, pageSize int) ([]Snippet, int, error) {
+	cond := `EXISTS (SELECT 1 FROM snippet_tags st
+		INNER JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id AND t.name = $1)`
+	return db.listPublic(cond, []any{tag}, page, pageSize)
+}
+
+// TagCounts returns the limit most used tags among listed snippets, sorted
+// by name.
+func (db *SnippetDB) TagCounts(limit int) ([]TagCount, error) {
+	stmt := `SELECT name, n FROM (
+		SELECT t.name, count(*) AS n FROM tags t
+		INNER JOIN snippet_tags st ON st.tag_id = t.id
+		INNER JOIN snippets s ON s.id = st.snippet_id
+		WHERE ` + listed + `
+		GROUP BY t.name ORDER BY n DESC, t.name LIMIT $1
+	) c ORDER BY name`
+	row, err := db.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, fmt.Errorf("models: select tag counts: %s", err)
+	}
+	defer row.Close()
+
+	var counts []TagCount
+	for row.Next() {
+		var c TagCount
+		err := row.Scan(&c.Name, &c.Count)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan tag count row: %s", err)
+		}
+		counts = append(counts, c)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate tag count row: %s", err)
+	}
+
+	return counts, nil
+}
@@ -2,16 +2,6 @@
 
 SET search_path TO app;
 
-CREATE TABLE snippets (
-    id serial NOT NULL PRIMARY KEY,
-    title VARCHAR(100) NOT NULL,
-    content TEXT NOT NULL,
-    created TIMESTAMP NOT NULL,
-    expires TIMESTAMP NOT NULL
-);
-
-CREATE INDEX idx_snippets_created ON snippets(created);
-
 CREATE TABLE users (
     id serial NOT NULL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
@@ -29,6 +19,182 @@ INSERT INTO users (name, email, hashed_password, created) VALUES (
     '2023-05-09 10:00:00'
 );
 
+CREATE TABLE snippets (
+    id serial NOT NULL PRIMARY KEY,
+    slug VARCHAR(16) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT 'plaintext',
+    created TIMESTAMP NOT NULL,
+    -- expires is worked out by the app, so it holds a time zone to be
+    -- compared with NOW() whatever the zones of the app and the database.
+    expires TIMESTAMPTZ,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    hashed_password CHAR(60),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'unlisted', 'private')),
+    forked_from INTEGER REFERENCES snippets(id) ON DELETE SET NULL,
+    -- search_vector covers the title and every file. It's set when the
+    -- snippet is saved, since a generated column can't see snippet_files.
+    search_vector TSVECTOR
+);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
+CREATE INDEX idx_snippets_search_vector ON snippets USING GIN (search_vector);
+
+CREATE TABLE snippet_files (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, position)
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name);
+
+CREATE TABLE snippet_revisions (
+    id serial NOT NULL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL,
+    editor_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created TIMESTAMP NOT NULL
+);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision);
+
+CREATE TABLE snippet_revision_files (
+    revision_id INTEGER NOT NULL REFERENCES snippet_revisions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL,
+    PRIMARY KEY (revision_id, position)
+);
+
+CREATE TABLE tags (
+    id serial NOT NULL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags(tag_id);
+
+CREATE TABLE comments (
+    id serial NOT NULL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_comments_snippet_id ON comments(snippet_id);
+
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    created TIMESTAMP NOT NULL
+);
+
+ALTER TABLE stars ADD CONSTRAINT stars_uc_user_snippet UNIQUE (user_id, snippet_id);
+
+CREATE INDEX idx_stars_snippet_id_created ON stars(snippet_id, created);
+
+CREATE TABLE snippet_views (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day)
+);
+
+CREATE TABLE snippet_visitors (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    visitor CHAR(64) NOT NULL,
+    PRIMARY KEY (snippet_id, day, visitor)
+);
+
+CREATE TABLE snippet_referrers (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    referrer VARCHAR(255) NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, referrer)
+);
+
+CREATE TABLE tokens (
+    id serial NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires TIMESTAMP,
+    created TIMESTAMP NOT NULL,
+    last_used TIMESTAMP
+);
+
+ALTER TABLE tokens ADD CONSTRAINT tokens_uc_hash UNIQUE (hash);
+
+CREATE INDEX idx_tokens_user_id ON tokens(user_id);
+
+CREATE TABLE webhooks (
+    id serial NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events TEXT[] NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+
+-- webhook_deliveries is the queue of events to send to webhooks. It copies
+-- the fields of the snippet the payload holds, since the snippet of an
+-- expired event is deleted.
+CREATE TABLE webhook_deliveries (
+    id serial NOT NULL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event VARCHAR(32) NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    slug VARCHAR(16) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    visibility VARCHAR(10) NOT NULL,
+    expires TIMESTAMPTZ,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
+CREATE TABLE webhook_attempts (
+    id serial NOT NULL PRIMARY KEY,
+    delivery_id INTEGER NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webhook_attempts_delivery_id ON webhook_attempts(delivery_id);
+
 -- CREATE ROLE test_readwrite;
 -- GRANT CONNECT ON DATABASE test_snippetbox TO test_readwrite;
 -- GRANT USAGE, CREATE ON SCHEMA app TO test_readwrite;
@@ -1,3 +1,17 @@
 SET search_path TO app;
+DROP TABLE webhook_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
+DROP TABLE tokens;
+DROP TABLE snippet_referrers;
+DROP TABLE snippet_visitors;
+DROP TABLE snippet_views;
+DROP TABLE stars;
+DROP TABLE comments;
+DROP TABLE snippet_tags;
+DROP TABLE tags;
+DROP TABLE snippet_files;
+DROP TABLE snippet_revision_files;
+DROP TABLE snippet_revisions;
 DROP TABLE snippets;
 DROP TABLE users;
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// ScopeSnippetsRead lets a token read snippets.
+	ScopeSnippetsRead = "snippets:read"
+	// ScopeSnippetsWrite lets a token create, update and delete snippets.
+	ScopeSnippetsWrite = "snippets:write"
+
+	// TokenPrefix starts every personal access token, so leaked tokens are
+	// easy to recognize.
+	TokenPrefix = "sbp_"
+	tokenBytes  = 20
+)
+
+// Token is a personal access token of a user. The plaintext token is only
+// known when it's created; just its SHA-256 hash is stored.
+type Token struct {
+	ID     int
+	UserID int
+	Name   string
+	Scopes []string
+	// Expires is the zero time for tokens which never expire.
+	Expires time.Time
+	Created time.Time
+	// LastUsed is the zero time for tokens which have never been used.
+	LastUsed time.Time
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Tokens interface {
+	Insert(t *Token) (string, error)
+	ByUser(userID int) ([]Token, error)
+	Delete(id int, userID int) error
+	Authenticate(plaintext string) (*Token, error)
+}
+
+type TokenDB struct {
+	DB *sql.DB
+}
+
+const tokenColumns = "id, user_id, name, scopes, expires, created, last_used"
+
+func tokenFields(t *Token) []any {
+	return []any{&t.ID, &t.UserID, &t.Name, pq.Array(&t.Scopes), nullTime{&t.Expires}, &t.Created, nullTime{&t.LastUsed}}
+}
+
+// Insert stores a new token, filling in its ID and creation time, and returns
+// the plaintext token.
+func (db *TokenDB) Insert(t *Token) (string, error) {
+	plaintext, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, scopes, expires, created)
+	VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING id, created`
+	err = db.DB.QueryRow(stmt, t.UserID, t.Name, hashToken(plaintext), pq.Array(t.Scopes), expiresParam(t.Expires)).Scan(&t.ID, &t.Created)
+	if err != nil {
+		return "", fmt.Errorf("models: insert a token: %s", err)
+	}
+
+	return plaintext, nil
+}
+
+// ByUser returns the tokens of a user, newest first, expired ones included.
+func (db *TokenDB) ByUser(userID int) ([]Token, error) {
+	stmt := `SELECT ` + tokenColumns + ` FROM tokens WHERE user_id = $1 ORDER BY created DESC, id DESC`
+	row, err := db.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("models: select tokens of a user: %s", err)
+	}
+	defer row.Close()
+
+	var tokens []Token
+	for row.Next() {
+		var t Token
+		err := row.Scan(tokenFields(&t)...)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan token row: %s", err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate token row: %s", err)
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes a token of a user. It returns ErrNoRecord when the user has
+// no such token.
+func (db *TokenDB) Delete(id int, userID int) error {
+	result, err := db.DB.Exec("DELETE FROM tokens WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("models: delete a token: %s", err)
+	}
+
+	return checkAffected(result)
+}
+
+// Authenticate returns the unexpired token matching plaintext and records
+// that it was used. It returns ErrInvalidCredentials when there's none.
+func (db *TokenDB) Authenticate(plaintext string) (*Token, error) {
+	if !strings.HasPrefix(plaintext, TokenPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	var t Token
+	stmt := `UPDATE tokens SET last_used = NOW()
+	WHERE hash = $1 AND (expires IS NULL OR expires > NOW())
+	RETURNING ` + tokenColumns
+	err := db.DB.QueryRow(stmt, hashToken(plaintext)).Scan(tokenFields(&t)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, fmt.Errorf("models: authenticate a token: %s", err)
+	}
+
+	return &t, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("models: generate a token: %s", err)
+	}
+
+	return TokenPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)), nil
+}
+
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestTokens(t *testing.T) {
+	db := newTestDB(t)
+	m := &TokenDB{db}
+
+	token := &Token{
+		UserID: 1,
+		Name:   "CI",
+		Scopes: []string{ScopeSnippetsRead},
+	}
+	plaintext, err := m.Insert(token)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.HasPrefix(plaintext, TokenPrefix), true)
+
+	var hash string
+	err = db.QueryRow("SELECT hash FROM tokens WHERE id = $1", token.ID).Scan(&hash)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hash, hashToken(plaintext))
+
+	got, err := m.Authenticate(plaintext)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, got.UserID, 1)
+	assert.Equal(t, got.HasScope(ScopeSnippetsRead), true)
+	assert.Equal(t, got.HasScope(ScopeSnippetsWrite), false)
+	assert.Equal(t, got.LastUsed.IsZero(), false)
+
+	_, err = m.Authenticate(TokenPrefix + "wrong")
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	expired := &Token{UserID: 1, Name: "Old", Scopes: []string{ScopeSnippetsRead}, Expires: time.Now().Add(-time.Hour)}
+	expiredPlaintext, err := m.Insert(expired)
+	assert.Equal(t, err, nil)
+	_, err = m.Authenticate(expiredPlaintext)
+	assert.Equal(t, err, ErrInvalidCredentials)
+
+	tokens, err := m.ByUser(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(tokens), 2)
+
+	assert.Equal(t, m.Delete(token.ID, 2), ErrNoRecord)
+	assert.Equal(t, m.Delete(token.ID, 1), nil)
+	_, err = m.Authenticate(plaintext)
+	assert.Equal(t, err, ErrInvalidCredentials)
+}
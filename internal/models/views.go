@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ViewBatch holds snippet views counted in memory since the last time they
+// were recorded. Days are dates in UTC.
+type ViewBatch struct {
+	Views     []ViewCount
+	Visitors  []Visitor
+	Referrers []ReferrerCount
+}
+
+type ViewCount struct {
+	SnippetID int
+	Day       time.Time
+	Views     int
+}
+
+// Visitor is a reader of a snippet on a day, known by the hash of their IP
+// address and the day, so they're counted once per day without storing the
+// address itself.
+type Visitor struct {
+	SnippetID int
+	Day       time.Time
+	Hash      string
+}
+
+type ReferrerCount struct {
+	SnippetID int
+	// Referrer is the host of the page which linked to the snippet.
+	Referrer string
+	Views    int
+}
+
+type DayStats struct {
+	Day      time.Time
+	Views    int
+	Visitors int
+}
+
+type ViewStats struct {
+	Total int
+	// Days are sorted from the most recent and only hold days with views.
+	Days      []DayStats
+	Referrers []ReferrerCount
+}
+
+type Views interface {
+	Record(ctx context.Context, b *ViewBatch) error
+	Stats(snippetID int, since time.Time, referrers int) (*ViewStats, error)
+}
+
+type ViewDB struct {
+	DB *sql.DB
+}
+
+const dayFormat = "2006-01-02"
+
+// Record adds a batch of views to the stored counters. Views of snippets
+// which have been deleted since are dropped, without failing the rest of the
+// batch.
+func (db *ViewDB) Record(ctx context.Context, b *ViewBatch) error {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("models: begin a transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	live, err := lockSnippets(ctx, tx, b)
+	if err != nil {
+		return err
+	}
+	b = b.only(live)
+
+	if len(b.Views) > 0 {
+		ids, days, views := make([]int64, len(b.Views)), make([]string, len(b.Views)), make([]int64, len(b.Views))
+		for i, v := range b.Views {
+			ids[i], days[i], views[i] = int64(v.SnippetID), v.Day.Format(dayFormat), int64(v.Views)
+		}
+
+		stmt := `INSERT INTO snippet_views (snippet_id, day, views)
+		SELECT v.snippet_id, v.day, v.views FROM unnest($1::int[], $2::date[], $3::int[]) AS v(snippet_id, day, views)
+		ON CONFLICT (snippet_id, day) DO UPDATE SET views = snippet_views.views + EXCLUDED.views`
+		_, err = tx.ExecContext(ctx, stmt, pq.Array(ids), pq.Array(days), pq.Array(views))
+		if err != nil {
+			return fmt.Errorf("models: insert snippet views: %s", err)
+		}
+	}
+
+	if len(b.Visitors) > 0 {
+		ids, days, hashes := make([]int64, len(b.Visitors)), make([]string, len(b.Visitors)), make([]string, len(b.Visitors))
+		for i, v := range b.Visitors {
+			ids[i], days[i], hashes[i] = int64(v.SnippetID), v.Day.Format(dayFormat), v.Hash
+		}
+
+		stmt := `INSERT INTO snippet_visitors (snippet_id, day, visitor)
+		SELECT v.snippet_id, v.day, v.visitor FROM unnest($1::int[], $2::date[], $3::text[]) AS v(snippet_id, day, visitor)
+		ON CONFLICT DO NOTHING`
+		_, err = tx.ExecContext(ctx, stmt, pq.Array(ids), pq.Array(days), pq.Array(hashes))
+		if err != nil {
+			return fmt.Errorf("models: insert snippet visitors: %s", err)
+		}
+	}
+
+	if len(b.Referrers) > 0 {
+		ids, referrers, views := make([]int64, len(b.Referrers)), make([]string, len(b.Referrers)), make([]int64, len(b.Referrers))
+		for i, r := range b.Referrers {
+			ids[i], referrers[i], views[i] = int64(r.SnippetID), r.Referrer, int64(r.Views)
+		}
+
+		stmt := `INSERT INTO snippet_referrers (snippet_id, referrer, views)
+		SELECT v.snippet_id, v.referrer, v.views FROM unnest($1::int[], $2::text[], $3::int[]) AS v(snippet_id, referrer, views)
+		ON CONFLICT (snippet_id, referrer) DO UPDATE SET views = snippet_referrers.views + EXCLUDED.views`
+		_, err = tx.ExecContext(ctx, stmt, pq.Array(ids), pq.Array(referrers), pq.Array(views))
+		if err != nil {
+			return fmt.Errorf("models: insert snippet referrers: %s", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("models: commit a transaction: %s", err)
+	}
+
+	return nil
+}
+
+// lockSnippets returns the IDs of the snippets of a batch which still exist,
+// and keeps them from being deleted until the transaction ends. Otherwise a
+// snippet deleted while the batch is recorded would fail it as a whole.
+func lockSnippets(ctx context.Context, tx *sql.Tx, b *ViewBatch) (map[int]bool, error) {
+	var ids []int64
+	for _, v := range b.Views {
+		ids = append(ids, int64(v.SnippetID))
+	}
+	for _, v := range b.Visitors {
+		ids = append(ids, int64(v.SnippetID))
+	}
+	for _, r := range b.Referrers {
+		ids = append(ids, int64(r.SnippetID))
+	}
+
+	stmt := "SELECT id FROM snippets WHERE id = ANY($1) ORDER BY id FOR KEY SHARE"
+	row, err := tx.QueryContext(ctx, stmt, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("models: lock viewed snippets: %s", err)
+	}
+	defer row.Close()
+
+	live := map[int]bool{}
+	for row.Next() {
+		var id int
+		err := row.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan snippet id row: %s", err)
+		}
+		live[id] = true
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate snippet id row: %s", err)
+	}
+
+	return live, nil
+}
+
+// only returns the part of the batch about the given snippets.
+func (b *ViewBatch) only(snippetIDs map[int]bool) *ViewBatch {
+	var kept ViewBatch
+	for _, v := range b.Views {
+		if snippetIDs[v.SnippetID] {
+			kept.Views = append(kept.Views, v)
+		}
+	}
+	for _, v := range b.Visitors {
+		if snippetIDs[v.SnippetID] {
+			kept.Visitors = append(kept.Visitors, v)
+		}
+	}
+	for _, r := range b.Referrers {
+		if snippetIDs[r.SnippetID] {
+			kept.Referrers = append(kept.Referrers, r)
+		}
+	}
+	return &kept
+}
+
+// Stats returns the total views of a snippet, its views and unique visitors
+// per day since the given day, and its top referrers.
+func (db *ViewDB) Stats(snippetID int, since time.Time, referrers int) (*ViewStats, error) {
+	var stats ViewStats
+	stmt := "SELECT COALESCE(sum(views), 0) FROM snippet_views WHERE snippet_id = $1"
+	err := db.DB.QueryRow(stmt, snippetID).Scan(&stats.Total)
+	if err != nil {
+		return nil, fmt.Errorf("models: select total views: %s", err)
+	}
+
+	stmt = `SELECT v.day, v.views,
+		(SELECT count(*) FROM snippet_visitors vi WHERE vi.snippet_id = v.snippet_id AND vi.day = v.day)
+	FROM snippet_views v
+	WHERE v.snippet_id = $1 AND v.day >= $2::date
+	ORDER BY v.day DESC`
+	row, err := db.DB.Query(stmt, snippetID, since.UTC().Format(dayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("models: select daily views: %s", err)
+	}
+	defer row.Close()
+
+	for row.Next() {
+		var d DayStats
+		err := row.Scan(&d.Day, &d.Views, &d.Visitors)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan daily views row: %s", err)
+		}
+		stats.Days = append(stats.Days, d)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate daily views row: %s", err)
+	}
+
+	stmt = `SELECT snippet_id, referrer, views FROM snippet_referrers
+	WHERE snippet_id = $1 ORDER BY views DESC, referrer LIMIT $2`
+	row, err = db.DB.Query(stmt, snippetID, referrers)
+	if err != nil {
+		return nil, fmt.Errorf("models: select referrers: %s", err)
+	}
+	defer row.Close()
+
+	for row.Next() {
+		var r ReferrerCount
+		err := row.Scan(&r.SnippetID, &r.Referrer, &r.Views)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan referrer row: %s", err)
+		}
+		stats.Referrers = append(stats.Referrers, r)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate referrer row: %s", err)
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestViewsRecordSkipsDeletedSnippets(t *testing.T) {
+	db := newTestDB(t)
+	m := &ViewDB{db}
+
+	stmt := `INSERT INTO snippets (slug, title, content, created, expires, user_id) VALUES
+	('pondXy12Ab', 'An old silent pond', 'An old silent pond...', NOW(), NOW() + INTERVAL '1 day', 1)`
+	_, err := db.Exec(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	b := &ViewBatch{
+		Views:     []ViewCount{{SnippetID: 1, Day: day, Views: 3}, {SnippetID: 99, Day: day, Views: 2}},
+		Visitors:  []Visitor{{SnippetID: 1, Day: day, Hash: "a"}, {SnippetID: 99, Day: day, Hash: "b"}},
+		Referrers: []ReferrerCount{{SnippetID: 1, Referrer: "example.com", Views: 1}, {SnippetID: 99, Referrer: "example.com", Views: 1}},
+	}
+	err = m.Record(context.Background(), b)
+	assert.Equal(t, err, nil)
+
+	stats, err := m.Stats(1, day, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stats.Total, 3)
+	assert.Equal(t, len(stats.Days), 1)
+	assert.Equal(t, stats.Days[0].Visitors, 1)
+	assert.Equal(t, len(stats.Referrers), 1)
+}
@@ -0,0 +1,266 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// EventSnippetCreated is sent when a user creates or forks a snippet.
+	EventSnippetCreated = "snippet.created"
+	// EventSnippetExpired is sent when an expired snippet of a user is
+	// deleted.
+	EventSnippetExpired = "snippet.expired"
+)
+
+// Statuses of webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a URL which is sent the events of a user's snippets. Its secret
+// is stored as is, since it's needed to sign the payloads.
+type Webhook struct {
+	ID      int
+	UserID  int
+	URL     string
+	Secret  string
+	Events  []string
+	Created time.Time
+}
+
+// Delivery is an event queued to be sent to a webhook, along with the URL
+// and secret of the webhook. Snippet only holds the ID, slug, title,
+// visibility and expiry of the snippet, as it may have been deleted since.
+type Delivery struct {
+	ID        int
+	WebhookID int
+	URL       string
+	Secret    string
+	Event     string
+	Snippet   Snippet
+	// Attempts is how many times the delivery has been tried so far.
+	Attempts int
+	Created  time.Time
+}
+
+// DeliveryAttempt is an entry of the delivery log. StatusCode is 0 when no
+// response was received, in which case Error says why.
+type DeliveryAttempt struct {
+	ID         int
+	DeliveryID int
+	WebhookID  int
+	URL        string
+	Event      string
+	Attempt    int
+	StatusCode int
+	Error      string
+	Created    time.Time
+}
+
+// Succeeded reports whether the webhook accepted the delivery.
+func (a *DeliveryAttempt) Succeeded() bool {
+	return a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+type Webhooks interface {
+	Insert(w *Webhook) error
+	ByUser(userID int) ([]Webhook, error)
+	Delete(id int, userID int) error
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	RecordAttempt(ctx context.Context, a *DeliveryAttempt, retryAt time.Time) error
+	Attempts(userID int, limit int) ([]DeliveryAttempt, error)
+}
+
+type WebhookDB struct {
+	DB *sql.DB
+}
+
+// Insert stores a new webhook, filling in its ID and creation time.
+func (db *WebhookDB) Insert(w *Webhook) error {
+	stmt := `INSERT INTO webhooks (user_id, url, secret, events, created)
+	VALUES ($1, $2, $3, $4, NOW()) RETURNING id, created`
+	err := db.DB.QueryRow(stmt, w.UserID, w.URL, w.Secret, pq.Array(w.Events)).Scan(&w.ID, &w.Created)
+	if err != nil {
+		return fmt.Errorf("models: insert a webhook: %s", err)
+	}
+
+	return nil
+}
+
+// ByUser returns the webhooks of a user, oldest first.
+func (db *WebhookDB) ByUser(userID int) ([]Webhook, error) {
+	stmt := `SELECT id, user_id, url, secret, events, created FROM webhooks
+	WHERE user_id = $1 ORDER BY created, id`
+	row, err := db.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("models: select webhooks of a user: %s", err)
+	}
+	defer row.Close()
+
+	var webhooks []Webhook
+	for row.Next() {
+		var w Webhook
+		err := row.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, pq.Array(&w.Events), &w.Created)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan webhook row: %s", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate webhook row: %s", err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes a webhook of a user along with its queued deliveries and
+// log. It returns ErrNoRecord when the user has no such webhook.
+func (db *WebhookDB) Delete(id int, userID int) error {
+	result, err := db.DB.Exec("DELETE FROM webhooks WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("models: delete a webhook: %s", err)
+	}
+
+	return checkAffected(result)
+}
+
+// enqueueWebhooks queues an event about a snippet for each webhook of its
+// author subscribed to the event. It's called in the transaction saving the
+// snippet, so the event is queued if and only if the snippet is saved.
+func enqueueWebhooks(tx *sql.Tx, event string, snippetID int) error {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, snippet_id, slug, title, visibility, expires, next_attempt, created)
+	SELECT w.id, $1::text, s.id, s.slug, s.title, s.visibility, s.expires, NOW(), NOW()
+	FROM snippets s INNER JOIN webhooks w ON w.user_id = s.user_id AND $1 = ANY(w.events)
+	WHERE s.id = $2`
+	_, err := tx.Exec(stmt, event, snippetID)
+	if err != nil {
+		return fmt.Errorf("models: enqueue webhook deliveries: %s", err)
+	}
+
+	return nil
+}
+
+// Claim returns at most limit deliveries which are due, oldest first, and
+// holds them back from other claims for the lease, so each is sent by one
+// worker at a time.
+func (db *WebhookDB) Claim(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	stmt := `UPDATE webhook_deliveries d SET next_attempt = NOW() + make_interval(secs => $2)
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt <= NOW()
+		ORDER BY next_attempt LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING d.id, d.webhook_id, w.url, w.secret, d.event, d.snippet_id, d.slug, d.title, d.visibility, d.expires, d.attempts, d.created`
+	row, err := db.DB.QueryContext(ctx, stmt, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("models: claim webhook deliveries: %s", err)
+	}
+	defer row.Close()
+
+	var deliveries []Delivery
+	for row.Next() {
+		var d Delivery
+		err := row.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Snippet.ID, &d.Snippet.Slug, &d.Snippet.Title, &d.Snippet.Visibility, nullTime{&d.Snippet.Expires}, &d.Attempts, &d.Created)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan webhook delivery row: %s", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate webhook delivery row: %s", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordAttempt logs an attempt at a delivery and updates the delivery:
+// it's done once the attempt succeeded, retried at retryAt otherwise, or
+// given up on when retryAt is the zero time.
+func (db *WebhookDB) RecordAttempt(ctx context.Context, a *DeliveryAttempt, retryAt time.Time) error {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("models: begin a transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	var statusCode sql.NullInt64
+	if a.StatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(a.StatusCode), Valid: true}
+	}
+	stmt := `INSERT INTO webhook_attempts (delivery_id, attempt, status_code, error, created)
+	VALUES ($1, $2, $3, $4, NOW()) RETURNING id, created`
+	err = tx.QueryRowContext(ctx, stmt, a.DeliveryID, a.Attempt, statusCode, a.Error).Scan(&a.ID, &a.Created)
+	if err != nil {
+		return fmt.Errorf("models: insert a webhook attempt: %s", err)
+	}
+
+	status := DeliveryPending
+	switch {
+	case a.Succeeded():
+		status = DeliveryDelivered
+	case retryAt.IsZero():
+		status = DeliveryFailed
+	}
+	stmt = `UPDATE webhook_deliveries SET attempts = $2, status = $3, next_attempt = COALESCE($4::timestamp, next_attempt)
+	WHERE id = $1`
+	result, err := tx.ExecContext(ctx, stmt, a.DeliveryID, a.Attempt, status, expiresParam(retryAt))
+	if err != nil {
+		return fmt.Errorf("models: update a webhook delivery: %s", err)
+	}
+	err = checkAffected(result)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("models: commit a transaction: %s", err)
+	}
+
+	return nil
+}
+
+// Attempts returns the latest entries of the delivery log of a user's
+// webhooks, newest first.
+func (db *WebhookDB) Attempts(userID int, limit int) ([]DeliveryAttempt, error) {
+	stmt := `SELECT a.id, a.delivery_id, w.id, w.url, d.event, a.attempt, COALESCE(a.status_code, 0), a.error, a.created
+	FROM webhook_attempts a
+	INNER JOIN webhook_deliveries d ON d.id = a.delivery_id
+	INNER JOIN webhooks w ON w.id = d.webhook_id
+	WHERE w.user_id = $1
+	ORDER BY a.created DESC, a.id DESC LIMIT $2`
+	row, err := db.DB.Query(stmt, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("models: select webhook attempts: %s", err)
+	}
+	defer row.Close()
+
+	var attempts []DeliveryAttempt
+	for row.Next() {
+		var a DeliveryAttempt
+		err := row.Scan(&a.ID, &a.DeliveryID, &a.WebhookID, &a.URL, &a.Event, &a.Attempt, &a.StatusCode, &a.Error, &a.Created)
+		if err != nil {
+			return nil, fmt.Errorf("models: scan webhook attempt row: %s", err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	err = row.Err()
+	if err != nil {
+		return nil, fmt.Errorf("models: iterate webhook attempt row: %s", err)
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/huytran2000-hcmus/snippetbox/internal/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	db := newTestDB(t)
+	m := &WebhookDB{db}
+	ctx := context.Background()
+
+	w := &Webhook{
+		UserID: 1,
+		URL:    "https://example.com/hooks",
+		Secret: "a very secret secret",
+		Events: []string{EventSnippetCreated},
+	}
+	err := m.Insert(w)
+	assert.Equal(t, err, nil)
+
+	webhooks, err := m.ByUser(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(webhooks), 1)
+	assert.Equal(t, webhooks[0].URL, w.URL)
+	assert.Equal(t, len(webhooks[0].Events), 1)
+
+	s := &Snippet{Title: "An old silent pond", Content: "An old silent pond...", Visibility: VisibilityPublic, UserID: 1}
+	err = (&SnippetDB{db}).Insert(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := m.Claim(ctx, 10, time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(deliveries), 1)
+	d := deliveries[0]
+	assert.Equal(t, d.URL, w.URL)
+	assert.Equal(t, d.Secret, w.Secret)
+	assert.Equal(t, d.Event, EventSnippetCreated)
+	assert.Equal(t, d.Snippet.Slug, s.Slug)
+	assert.Equal(t, d.Snippet.Expires.IsZero(), true)
+
+	// A claimed delivery is held back until its lease is over.
+	deliveries, err = m.Claim(ctx, 10, time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(deliveries), 0)
+
+	err = m.RecordAttempt(ctx, &DeliveryAttempt{DeliveryID: d.ID, Attempt: 1, Error: "connection refused"}, time.Now().Add(-time.Second))
+	assert.Equal(t, err, nil)
+	deliveries, err = m.Claim(ctx, 10, time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(deliveries), 1)
+	assert.Equal(t, deliveries[0].Attempts, 1)
+
+	err = m.RecordAttempt(ctx, &DeliveryAttempt{DeliveryID: d.ID, Attempt: 2, StatusCode: 204}, time.Time{})
+	assert.Equal(t, err, nil)
+	var status string
+	err = db.QueryRow("SELECT status FROM webhook_deliveries WHERE id = $1", d.ID).Scan(&status)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status, DeliveryDelivered)
+
+	attempts, err := m.Attempts(1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(attempts), 2)
+	assert.Equal(t, attempts[0].Attempt, 2)
+	assert.Equal(t, attempts[0].StatusCode, 204)
+	assert.Equal(t, attempts[1].StatusCode, 0)
+	assert.Equal(t, attempts[1].Error, "connection refused")
+
+	assert.Equal(t, m.Delete(w.ID, 2), ErrNoRecord)
+	assert.Equal(t, m.Delete(w.ID, 1), nil)
+	attempts, err = m.Attempts(1, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(attempts), 0)
+}
+
+func TestSnippetDeleteExpiredQueuesWebhooks(t *testing.T) {
+	db := newTestDB(t)
+	m := &WebhookDB{db}
+
+	err := m.Insert(&Webhook{UserID: 1, URL: "https://example.com/hooks", Secret: "a very secret secret", Events: []string{EventSnippetExpired}})
+	assert.Equal(t, err, nil)
+
+	stmt := `INSERT INTO snippets (slug, title, content, created, expires, user_id) VALUES
+	('expiredA01', 'Expired', 'Expired', NOW(), NOW() - INTERVAL '1 day', 1),
+	('currentB02', 'Current', 'Current', NOW(), NOW() + INTERVAL '1 day', 1)`
+	_, err = db.Exec(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := (&SnippetDB{db}).DeleteExpired(context.Background(), time.Now(), 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, 1)
+
+	deliveries, err := m.Claim(context.Background(), 10, time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(deliveries), 1)
+	assert.Equal(t, deliveries[0].Event, EventSnippetExpired)
+	assert.Equal(t, deliveries[0].Snippet.Slug, "expiredA01")
+}
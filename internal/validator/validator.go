@@ -1,9 +1,11 @@
 package validator
 
 import (
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -12,6 +14,7 @@ var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9
 type Validator struct {
 	fieldName    string
 	fieldValue   string
+	fieldItems   []string
 	NonFieldErrs []string
 	FieldErrs    map[string]string
 }
@@ -23,6 +26,7 @@ func (v *Validator) IsValid() bool {
 func (v *Validator) CheckField(name string, val string) *Validator {
 	v.fieldName = name
 	v.fieldValue = val
+	v.fieldItems = nil
 	return v
 }
 
@@ -49,6 +53,14 @@ func (v *Validator) ToInt(message string) int {
 	return i
 }
 
+func (v *Validator) ToTime(message string, layout string, loc *time.Location) time.Time {
+	t, err := time.ParseInLocation(layout, v.fieldValue, loc)
+	if err != nil {
+		v.addFieldError(message)
+	}
+	return t
+}
+
 func (v *Validator) NotBlank(message string) *Validator {
 	val := strings.TrimSpace(v.fieldValue)
 	if val == "" {
@@ -85,6 +97,15 @@ func (v *Validator) GE(message string, n int) *Validator {
 	return v
 }
 
+func (v *Validator) IntBetween(message string, min int, max int) *Validator {
+	i, err := strconv.Atoi(v.fieldValue)
+	if err != nil || i < min || i > max {
+		v.addFieldError(message)
+	}
+
+	return v
+}
+
 func (v *Validator) Matches(message string, rx *regexp.Regexp) *Validator {
 	ok := rx.MatchString(v.fieldValue)
 	if !ok {
@@ -98,6 +119,25 @@ func (v *Validator) IsEmail(message string) *Validator {
 	return v.Matches(message, EmailRX)
 }
 
+// IsURL checks that the field is an absolute URL with a host and one of the
+// given schemes.
+func (v *Validator) IsURL(message string, schemes ...string) *Validator {
+	u, err := url.Parse(v.fieldValue)
+	if err != nil || u.Host == "" {
+		v.addFieldError(message)
+		return v
+	}
+
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return v
+		}
+	}
+
+	v.addFieldError(message)
+	return v
+}
+
 func (v *Validator) Equal(message string, val string) *Validator {
 	if v.fieldValue != val {
 		v.addFieldError(message)
@@ -106,6 +146,81 @@ func (v *Validator) Equal(message string, val string) *Validator {
 	return v
 }
 
+// Split splits the field value on sep into trimmed items, dropping empty and
+// repeated ones. The Items methods check these items.
+func (v *Validator) Split(sep string) *Validator {
+	v.fieldItems = nil
+	seen := map[string]bool{}
+	for _, item := range strings.Split(v.fieldValue, sep) {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+
+		seen[item] = true
+		v.fieldItems = append(v.fieldItems, item)
+	}
+
+	return v
+}
+
+func (v *Validator) MaxItems(message string, n int) *Validator {
+	if len(v.fieldItems) > n {
+		v.addFieldError(message)
+	}
+
+	return v
+}
+
+func (v *Validator) MinItems(message string, n int) *Validator {
+	if len(v.fieldItems) < n {
+		v.addFieldError(message)
+	}
+
+	return v
+}
+
+func (v *Validator) ItemsIn(message string, permittedArrs ...string) *Validator {
+	for _, item := range v.fieldItems {
+		permitted := false
+		for _, p := range permittedArrs {
+			if item == p {
+				permitted = true
+				break
+			}
+		}
+
+		if !permitted {
+			v.addFieldError(message)
+			break
+		}
+	}
+
+	return v
+}
+
+func (v *Validator) ItemsLE(message string, n int) *Validator {
+	for _, item := range v.fieldItems {
+		if utf8.RuneCountInString(item) > n {
+			v.addFieldError(message)
+			break
+		}
+	}
+
+	return v
+}
+
+func (v *Validator) ItemsMatch(message string, rx *regexp.Regexp) *Validator {
+	for _, item := range v.fieldItems {
+		if !rx.MatchString(item) {
+			v.addFieldError(message)
+			break
+		}
+	}
+
+	return v
+}
+
 func (v *Validator) addFieldError(message string) {
 	v.AddFieldError(v.fieldName, message)
 }
@@ -113,3 +228,7 @@ func (v *Validator) addFieldError(message string) {
 func (v *Validator) Value() string {
 	return v.fieldValue
 }
+
+func (v *Validator) Items() []string {
+	return v.fieldItems
+}
@@ -4,3 +4,8 @@ import "embed"
 
 //go:embed "html" "static"
 var Files embed.FS
+
+// OpenAPI is the OpenAPI 3 document describing the HTTP API.
+//
+//go:embed "openapi.json"
+var OpenAPI []byte